@@ -0,0 +1,189 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"tg-group-control-bot/internal/config"
+	"tg-group-control-bot/internal/names"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/pkg/errors"
+)
+
+const adminSyncInterval = 6 * time.Hour
+
+// isChatAdmin checks that user is admin of chat with rights to manage bot
+func (b *Bot) isChatAdmin(chatID int64, userID int) bool {
+	return b.isChatAdminWith(chatID, userID, config.AdminRights.CanManageBot)
+}
+
+// isChatAdminWith checks that user is admin of chat whose rights are accepted by allowed.
+// Before admins are synchronized with telegram their rights are unknown, so any admin is accepted.
+func (b *Bot) isChatAdminWith(chatID int64, userID int, allowed func(config.AdminRights) bool) bool {
+	ch, err := b.DB.GetChatInfo(chatID)
+	if err != nil {
+		return false
+	}
+
+	// Admins were not synchronized with telegram yet
+	if len(ch.AdminRights) == 0 {
+		for _, adm := range ch.Admins {
+			if adm == userID {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, r := range ch.AdminRights {
+		if r.ID == userID {
+			return allowed(r)
+		}
+	}
+	return false
+}
+
+// anyAdminRights accepts admin with any rights, it is used for admins adding members and bots
+func anyAdminRights(config.AdminRights) bool {
+	return true
+}
+
+// notifyAdmins sends text to all admins of chat
+func (b *Bot) notifyAdmins(chatID int64, text string) {
+	for _, adm := range b.DB.GetChatAdmins(chatID) {
+		_, err := b.API.Send(tg.NewMessage(int64(adm), text))
+		if err != nil {
+			b.Log.Errorf("%+v", errors.Wrapf(err, "Error sending message to admin %d of chat %d.", adm, chatID))
+		}
+	}
+}
+
+// runAdminSync periodically synchronizes admins of all chats with telegram
+func (b *Bot) runAdminSync() {
+	ticker := time.NewTicker(adminSyncInterval)
+	defer ticker.Stop()
+	for {
+		ids, err := b.DB.GetChatIDs()
+		if err != nil {
+			b.Log.Errorf("%+v", err)
+		}
+		for _, id := range ids {
+			if err := b.syncAdmins(id); err != nil {
+				b.Log.Errorf("%+v", err)
+			}
+		}
+		<-ticker.C
+	}
+}
+
+// syncAdmins replaces stored admins of chat with admins received from telegram
+func (b *Bot) syncAdmins(chatID int64) error {
+	members, err := b.API.GetChatAdministrators(tg.ChatConfig{ChatID: chatID})
+	if err != nil {
+		return errors.Wrapf(err, "Failed get admins of chat %d", chatID)
+	}
+
+	admins := make([]config.AdminRights, 0, len(members))
+	for _, m := range members {
+		if m.User == nil || m.User.IsBot {
+			continue
+		}
+		admins = append(admins, config.AdminRights{
+			ID:                 m.User.ID,
+			Status:             m.Status,
+			CanChangeInfo:      m.CanChangeInfo,
+			CanDeleteMessages:  m.CanDeleteMessages,
+			CanInviteUsers:     m.CanInviteUsers,
+			CanRestrictMembers: m.CanRestrictMembers,
+			CanPinMessages:     m.CanPinMessages,
+			CanPromoteMembers:  m.CanPromoteMembers,
+		})
+	}
+
+	ch, chErr := b.DB.GetChatInfo(chatID)
+	err = b.DB.SetChatAdmins(chatID, admins)
+	if err != nil {
+		return errors.Wrapf(err, "Failed save admins of chat %d", chatID)
+	}
+
+	// Admins of chat which was just added are not changes
+	if chErr == nil && len(ch.AdminRights) > 0 {
+		if changes := b.adminChanges(ch, admins); len(changes) > 0 {
+			b.notifyAdmins(chatID, fmt.Sprintf("Изменились администраторы чата %s:\n%s", ch.Title, strings.Join(changes, "\n")))
+		}
+	}
+	return nil
+}
+
+// adminChanges logs changes of admins and returns their description for admins
+func (b *Bot) adminChanges(ch config.Chat, admins []config.AdminRights) []string {
+	changes := make([]string, 0)
+	old := make(map[int]config.AdminRights)
+	for _, r := range ch.AdminRights {
+		old[r.ID] = r
+	}
+
+	for _, r := range admins {
+		prev, exist := old[r.ID]
+		switch {
+		case !exist:
+			b.Log.Infof("User %d became admin of chat %d with status %s", r.ID, ch.ID, r.Status)
+			changes = append(changes, fmt.Sprintf("%s стал администратором", b.adminName(r.ID)))
+		case prev != r:
+			b.Log.Infof("Rights of admin %d in chat %d changed from %+v to %+v", r.ID, ch.ID, prev, r)
+			changes = append(changes, fmt.Sprintf("у %s изменились права", b.adminName(r.ID)))
+		}
+		delete(old, r.ID)
+	}
+	for id := range old {
+		b.Log.Infof("User %d is not admin of chat %d anymore", id, ch.ID)
+		changes = append(changes, fmt.Sprintf("%s больше не администратор", b.adminName(id)))
+	}
+	return changes
+}
+
+// adminName returns name of user known to bot or its ID
+func (b *Bot) adminName(userID int) string {
+	name := strconv.Itoa(userID)
+	if u, err := b.DB.GetUser(userID); err == nil && u.String() != "" {
+		name = u.String() + " (" + name + ")"
+	}
+	return name
+}
+
+// reloadCommand synchronizes admins of chat on demand
+func (b *Bot) reloadCommand(message *tg.Message) error {
+	if message.Chat.IsPrivate() {
+		_, err := b.API.Send(tg.NewMessage(message.Chat.ID, "Отправьте эту команду в группу, список администраторов которой нужно обновить"))
+		if err != nil {
+			return errors.Wrapf(err, "Error sending message in reloadCommand to %d.", message.Chat.ID)
+		}
+		return nil
+	}
+
+	// Stored admins may be outdated, so rights are checked in telegram
+	m, err := b.API.GetChatMember(tg.ChatConfigWithUser{
+		ChatID: message.Chat.ID,
+		UserID: message.From.ID,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Failed get member %s of chat %s", names.ShortUserName(message.From), names.ChatName(message.Chat))
+	}
+	if !m.IsCreator() && !m.IsAdministrator() {
+		return fmt.Errorf("User %s is not admin of chat %s and cannot reload admins", names.ShortUserName(message.From), names.ChatName(message.Chat))
+	}
+
+	err = b.syncAdmins(message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = b.API.Send(tg.NewMessage(message.Chat.ID, fmt.Sprintf("Список администраторов обновлён: %d", len(b.DB.GetChatAdmins(message.Chat.ID)))))
+	if err != nil {
+		return errors.Wrapf(err, "Error sending message in reloadCommand to %d.", message.Chat.ID)
+	}
+	return nil
+}
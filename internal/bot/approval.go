@@ -0,0 +1,192 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"tg-group-control-bot/internal/config"
+	"tg-group-control-bot/internal/names"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// New user is restricted until one of admins presses the button in request sent to private chat.
+// Callback data of buttons in approval request is approval:<approve|reject|ban>:<chatID>:<userID>
+const approvalPrefix = "approval"
+
+// holdForApproval restricts new user and sends approval request to all admins.
+// Zero inviterID means that user joined by itself.
+func (b *Bot) holdForApproval(message *tg.Message, u *tg.User, inviterID int) error {
+	// Remember restrictions made by admins to restore them after approval
+	restrictions, err := b.memberRestrictions(message.Chat.ID, u.ID)
+	if err != nil {
+		b.Log.Errorf("%+v", err)
+	}
+	err = b.DB.AddChatUser(message.Chat.ID, config.ChatUser{
+		ID:           u.ID,
+		Confirmed:    false,
+		Restrictions: restrictions,
+		InvitedBy:    inviterID,
+	})
+	if err != nil {
+		return errors.Wrap(err, "Failed add user to chat.")
+	}
+	err = b.restrictNewMember(message.Chat.ID, u.ID)
+	if err != nil {
+		return err
+	}
+	// Kick user if no admin decides in time, so user is not restricted forever
+	timeout := time.Duration(b.chatSettings(message.Chat.ID).ConfirmTimeout) * time.Second
+	err = b.schedule(jobKickUnconfirmed, message.Chat.ID, u.ID, timeout)
+	if err != nil {
+		return errors.Wrapf(err, "Error schedule kick of unapproved user %s.", names.FullUserName(u))
+	}
+
+	var inviter *tg.User
+	if inviterID != 0 {
+		inviter = message.From
+	}
+	text := approvalText(message.Chat, u, inviter)
+	markup := tg.NewInlineKeyboardMarkup(tg.NewInlineKeyboardRow(
+		tg.NewInlineKeyboardButtonData("Одобрить", callbackData(approvalPrefix, "approve", message.Chat.ID, u.ID)),
+		tg.NewInlineKeyboardButtonData("Отклонить", callbackData(approvalPrefix, "reject", message.Chat.ID, u.ID)),
+		tg.NewInlineKeyboardButtonData("Забанить", callbackData(approvalPrefix, "ban", message.Chat.ID, u.ID)),
+	))
+
+	refs := make([]config.Ref, 0)
+	for _, adm := range b.DB.GetChatAdmins(message.Chat.ID) {
+		msg := tg.NewMessage(int64(adm), text)
+		msg.ReplyMarkup = markup
+		res, err := b.API.Send(msg)
+		if err != nil {
+			b.Log.Errorf("%+v", errors.Wrapf(err, "Error sending approval request to admin %d of chat %s.", adm, names.ChatName(message.Chat)))
+			continue
+		}
+		refs = append(refs, config.Ref{ChatID: res.Chat.ID, MsgID: res.MessageID})
+	}
+	if len(refs) == 0 {
+		// Nobody can approve user, so user passes the test instead
+		b.Log.Warnf("No admin of chat %s received approval request for user %s, test is sent", names.ChatName(message.Chat), names.FullUserName(u))
+		return b.startVerification(message, u, inviterID)
+	}
+	err = b.DB.SetChatUserApprovals(message.Chat.ID, u.ID, refs)
+	if err != nil {
+		return errors.Wrapf(err, "Failed save approval requests for user %s", names.FullUserName(u))
+	}
+	b.Log.Infof("User %s waits for approval in chat %s", names.FullUserName(u), names.ChatName(message.Chat))
+	return nil
+}
+
+// approvalText describes new user for admins
+func approvalText(chat *tg.Chat, u *tg.User, inviter *tg.User) string {
+	lines := []string{
+		fmt.Sprintf("Заявка на вступление в чат %s", chat.Title),
+		fmt.Sprintf("Пользователь: %s", names.FullUserName(u)),
+		fmt.Sprintf("ID: %d", u.ID),
+	}
+	if u.UserName != "" {
+		lines = append(lines, "Username: @"+u.UserName)
+	}
+	if u.LanguageCode != "" {
+		lines = append(lines, "Язык: "+u.LanguageCode)
+	}
+	if inviter != nil {
+		lines = append(lines, fmt.Sprintf("Добавил: %s (%d)", names.FullUserName(inviter), inviter.ID))
+	} else {
+		lines = append(lines, "Вступил сам")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// approvalCallback resolves approval request. Only the first pressed button takes effect.
+func (b *Bot) approvalCallback(query *tg.CallbackQuery, args []string) (callbackAnswer, error) {
+	var answer callbackAnswer
+	if len(args) < 3 || query.Message == nil {
+		return answer, fmt.Errorf("Invalid approval callback %s", query.Data)
+	}
+	// Chat was checked in authChatAdmin
+	chatID, _ := strconv.ParseInt(args[1], 10, 64)
+	userID, err := strconv.Atoi(args[2])
+	if err != nil {
+		return answer, errors.Wrapf(err, "Invalid user in approval callback %s", query.Data)
+	}
+
+	var decision string
+	switch args[0] {
+	case "approve":
+		decision = "Одобрил"
+	case "reject":
+		decision = "Отклонил"
+	case "ban":
+		decision = "Забанил"
+	default:
+		return answer, fmt.Errorf("Unknown approval action %s", args[0])
+	}
+
+	refs, err := b.DB.ClaimApproval(chatID, userID)
+	if err == mongo.ErrNoDocuments {
+		b.editApproval(config.Ref{ChatID: query.Message.Chat.ID, MsgID: query.Message.MessageID}, query.Message.Text, "Заявка уже рассмотрена")
+		return callbackAnswer{Text: "Заявка уже рассмотрена"}, nil
+	}
+	if err != nil {
+		return answer, errors.Wrapf(err, "Failed claim approval of user %d in chat %d", userID, chatID)
+	}
+
+	switch args[0] {
+	case "approve":
+		if b.inLockdown(chatID) {
+			// Approval is not enough during raid, user passes captcha in private chat too
+			decision = "Одобрил (из-за блокировки пользователь проходит тест)"
+			err = b.sendWelcome(chatID, b.storedUser(userID), 0, "")
+		} else {
+			err = b.confirmUser(chatID, &tg.User{ID: userID})
+		}
+	case "reject":
+		err = b.kickPending(chatID, userID, 0)
+	case "ban":
+		// User is banned only in this chat, federation bans are made by /fedban
+		err = b.banSpammer(chatID, userID)
+		if err == nil {
+			b.recordEvent(chatID, userID, config.MemberKicked, query.From.ID)
+			if err := b.unschedule(jobKickUnconfirmed, chatID, userID); err != nil {
+				b.Log.Errorf("%+v", err)
+			}
+		}
+	}
+	if err != nil {
+		// Return requests to admins, so that decision can be made again
+		if err := b.DB.SetChatUserApprovals(chatID, userID, refs); err != nil {
+			b.Log.Errorf("%+v", err)
+		}
+		return answer, err
+	}
+
+	b.Log.Infof("Admin %s decided %s for user %d in chat %d", names.ShortUserName(query.From), args[0], userID, chatID)
+	for _, ref := range refs {
+		b.editApproval(ref, query.Message.Text, fmt.Sprintf("%s %s", decision, names.FullUserName(query.From)))
+	}
+	return callbackAnswer{Text: "Готово"}, nil
+}
+
+// storedUser returns telegram user with name saved in storage
+func (b *Bot) storedUser(userID int) *tg.User {
+	u := &tg.User{ID: userID}
+	if info, err := b.DB.GetUser(userID); err == nil {
+		u.FirstName = info.FirstName
+		u.LastName = info.LastName
+		u.UserName = info.UserName
+	}
+	return u
+}
+
+// editApproval appends decision to approval request and removes buttons
+func (b *Bot) editApproval(ref config.Ref, text, decision string) {
+	_, err := b.API.Send(tg.NewEditMessageText(ref.ChatID, ref.MsgID, text+"\n\n"+decision))
+	if err != nil {
+		b.Log.Errorf("%+v", errors.Wrapf(err, "Error edit approval request %d in chat %d", ref.MsgID, ref.ChatID))
+	}
+}
@@ -7,6 +7,8 @@ import (
 
 	"tg-group-control-bot/internal/config"
 	"tg-group-control-bot/internal/memo"
+	"tg-group-control-bot/internal/raid"
+	"tg-group-control-bot/internal/spam"
 	"tg-group-control-bot/internal/storage"
 
 	tg "github.com/go-telegram-bot-api/telegram-bot-api"
@@ -20,6 +22,8 @@ type Bot struct {
 	API    *tg.BotAPI
 	Log    *logrus.Logger
 	Memo   *memo.Memo
+	Spam   spam.Checker
+	Raid   *raid.Detector
 }
 
 // BotRequest contains some data of request
@@ -72,12 +76,30 @@ func Init() *Bot {
 
 	memo := memo.New()
 
+	policy, err := spam.ParsePolicy(cfg.SpamPolicy)
+	if err != nil {
+		log.Error(err)
+		os.Exit(1)
+	}
+	if cfg.SpamList != "" && cfg.SpamListEvery <= 0 {
+		log.Errorf("Invalid SPAM_LIST_INTERVAL %s, it must be positive", cfg.SpamListEvery)
+		os.Exit(1)
+	}
+	checker := spam.NewComposite(
+		policy,
+		spam.NewCAS(cfg.CASURL),
+		spam.NewBlocklist(cfg.SpamBlocklist...),
+	)
+	log.Infof("Spam checker %s", checker.Name())
+
 	return &Bot{
 		Config: cfg,
 		DB:     db,
 		API:    bot,
 		Log:    log,
 		Memo:   memo,
+		Spam:   checker,
+		Raid:   raid.New(),
 	}
 }
 
@@ -93,6 +115,11 @@ func (b *Bot) Start() {
 		os.Exit(1)
 	}
 
+	go b.runScheduler()
+	go b.runAdminSync()
+	go b.runSpamListRefresh()
+	go b.runSweep()
+
 	for update := range updates {
 		switch {
 		case update.EditedMessage != nil:
@@ -102,7 +129,7 @@ func (b *Bot) Start() {
 		case update.ChosenInlineResult != nil:
 			go b.logger(update, b.Stub)
 		case update.CallbackQuery != nil:
-			go b.logger(update, b.Stub)
+			go b.callbackLogger(update.CallbackQuery, b.HandleCallback)
 		case update.Message.IsCommand():
 			go b.logger(update, b.HandleCommand)
 		default:
@@ -128,13 +155,32 @@ func (b *Bot) logger(u tg.Update, h func(*tg.Message) error) {
 	// Log before handling
 	log.Infof("Started handling '%s' request", mt)
 	// Log after handling
-	defer log.Infof("Finished handling '%s' request. Duration %s", mt, time.Since(t))
+	defer func() {
+		log.Infof("Finished handling '%s' request. Duration %s", mt, time.Since(t))
+	}()
 	err = h(m)
 	if err != nil {
 		log.Errorf("Error handling '%s' request %+v", mt, err)
 	}
 }
 
+func (b *Bot) callbackLogger(q *tg.CallbackQuery, h func(*tg.CallbackQuery) error) {
+	t := time.Now()
+	log := b.Log.WithFields(logrus.Fields{
+		"requestID": t.UnixNano() / 1000,
+		"user":      q.From,
+	})
+
+	log.Infof("Started handling 'callback' request with data '%s'", q.Data)
+	defer func() {
+		log.Infof("Finished handling 'callback' request. Duration %s", time.Since(t))
+	}()
+	err := h(q)
+	if err != nil {
+		log.Errorf("Error handling 'callback' request %+v", err)
+	}
+}
+
 func (b *Bot) getMessage(u *tg.Update) (*tg.Message, error) {
 	switch {
 	case u.EditedMessage != nil:
@@ -143,8 +189,6 @@ func (b *Bot) getMessage(u *tg.Update) (*tg.Message, error) {
 		return &tg.Message{From: u.InlineQuery.From}, errors.New("tg.InlineQuery is not tg.Message")
 	case u.ChosenInlineResult != nil:
 		return &tg.Message{From: u.ChosenInlineResult.From}, errors.New("tg.ChosenInlineResult is not tg.Message")
-	case u.CallbackQuery != nil:
-		return &tg.Message{From: u.CallbackQuery.From}, errors.New("tg.CallbackQuery is not tg.Message")
 	case u.Message != nil:
 		return u.Message, nil
 	default:
@@ -0,0 +1,66 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"tg-group-control-bot/internal/names"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/pkg/errors"
+)
+
+// botAdded kicks bot added to chat by regular member unless bot is allowed in chat
+func (b *Bot) botAdded(message *tg.Message, bot *tg.User) error {
+	if b.isChatAdminWith(message.Chat.ID, message.From.ID, anyAdminRights) || b.isAllowedBot(message.Chat.ID, bot.ID) {
+		b.Log.Infof("Bot %s was added to chat %s by %s", names.FullUserName(bot), names.ChatName(message.Chat), names.FullUserName(message.From))
+		return nil
+	}
+
+	err := b.kickPending(message.Chat.ID, bot.ID, 0)
+	if err != nil {
+		b.notifyAdmins(message.Chat.ID, fmt.Sprintf("Участник %s (%d) добавил бота %s (%d) в чат %s. Не удалось удалить бота, проверьте права @%s.",
+			names.FullUserName(message.From), message.From.ID, names.FullUserName(bot), bot.ID, message.Chat.Title, b.API.Self.UserName))
+		return errors.Wrapf(err, "Failed kick bot %s added by %s", names.FullUserName(bot), names.FullUserName(message.From))
+	}
+
+	b.Log.Infof("Bot %s added by %s was kicked from chat %s", names.FullUserName(bot), names.FullUserName(message.From), names.ChatName(message.Chat))
+	b.notifyAdmins(message.Chat.ID, fmt.Sprintf("Участник %s (%d) добавил бота %s (%d) в чат %s. Бот удалён.\nЧтобы разрешить этого бота, отправьте в группу /allowbot %d",
+		names.FullUserName(message.From), message.From.ID, names.FullUserName(bot), bot.ID, message.Chat.Title, bot.ID))
+	return nil
+}
+
+// isAllowedBot checks that bot may be added to chat by any member
+func (b *Bot) isAllowedBot(chatID int64, botID int) bool {
+	ch, err := b.DB.GetChatInfo(chatID)
+	if err != nil {
+		return false
+	}
+	for _, id := range ch.AllowedBots {
+		if id == botID {
+			return true
+		}
+	}
+	return false
+}
+
+// allowBotCommand handles /allowbot <botID> and /denybot <botID> sent by admin to group chat
+func (b *Bot) allowBotCommand(message *tg.Message, allowed bool) error {
+	if !b.groupAdminAllowed(message) {
+		return nil
+	}
+
+	botID, err := strconv.Atoi(strings.TrimSpace(message.CommandArguments()))
+	if err != nil {
+		return b.replyGroup(message, fmt.Sprintf("Используйте: /%s <ID бота>", message.Command()))
+	}
+	err = b.DB.SetChatAllowedBot(message.Chat.ID, botID, allowed)
+	if err != nil {
+		return errors.Wrapf(err, "Failed change allowlist of bots in chat %s", names.ChatName(message.Chat))
+	}
+	if allowed {
+		return b.replyGroup(message, fmt.Sprintf("Бота %d может добавить любой участник", botID))
+	}
+	return b.replyGroup(message, fmt.Sprintf("Бота %d может добавить только администратор", botID))
+}
@@ -0,0 +1,230 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"tg-group-control-bot/internal/challenge"
+	"tg-group-control-bot/internal/config"
+	"tg-group-control-bot/internal/names"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/pkg/errors"
+)
+
+// Callback data of answer button is answer:<chatID>:<option index>
+const answerPrefix = "answer"
+
+// chatChallenge returns challenge configured for chat
+func (b *Bot) chatChallenge(chatID int64) challenge.Challenge {
+	if ch, ok := b.lockdownChatChallenge(chatID); ok {
+		return ch
+	}
+	name := b.chatSettings(chatID).Challenge
+	if name == challenge.Default {
+		// Use questions of chat admins if they exist
+		questions, err := b.DB.GetChatQuestions(chatID)
+		if err != nil {
+			b.Log.Errorf("%+v", errors.Wrapf(err, "Failed get questions of chat %d", chatID))
+		}
+		if len(questions) > 0 {
+			return challenge.Question{Items: questions}
+		}
+	}
+	return challenge.Get(name)
+}
+
+// newTask generates new task of chat challenge for user and saves it
+func (b *Bot) newTask(chatID int64, userID int) challenge.Task {
+	task := b.chatChallenge(chatID).Generate()
+	err := b.DB.UpdateChatUserTask(chatID, userID, task)
+	if err != nil {
+		b.Log.Errorf("%+v", errors.Wrapf(err, "Failed save task for user %d in chat %d", userID, chatID))
+	}
+	return task
+}
+
+// pendingTask returns task which was issued to user or generates new one
+func (b *Bot) pendingTask(chatID int64, userID int) challenge.Task {
+	cu, err := b.DB.GetChatUser(chatID, userID)
+	if err == nil && !cu.Task.Empty() {
+		return cu.Task
+	}
+	b.Log.Debugf("No pending task for user %d in chat %d, generating new one", userID, chatID)
+	return b.newTask(chatID, userID)
+}
+
+// verifyAnswer checks user's answer on the task of chat and replies in private chat
+func (b *Bot) verifyAnswer(chatID int64, user *tg.User, answer string) error {
+	task := b.pendingTask(chatID, user.ID)
+	if task.Check(answer) {
+		err := b.confirmUser(chatID, user)
+		if err != nil {
+			return err
+		}
+		// Send success message to user in bot chat
+		msg := b.TGMessageSuccess(chatID, int64(user.ID))
+		_, err = b.API.Send(msg)
+		if err != nil {
+			return errors.Wrapf(err, "Error sending success message to user %s.", names.ShortUserName(user))
+		}
+		return b.offerNextChat(user)
+	}
+
+	failures, err := b.DB.IncChatUserFailures(chatID, user.ID)
+	if err != nil {
+		b.Log.Errorf("%+v", errors.Wrapf(err, "Failed count wrong answers of user %s", names.ShortUserName(user)))
+	}
+	settings := b.chatSettings(chatID)
+	if b.inLockdown(chatID) {
+		settings.MaxAttempts = lockdownMaxAttempts
+	}
+	if settings.MaxAttempts > 0 && failures >= settings.MaxAttempts {
+		return b.failUser(chatID, user, settings)
+	}
+
+	task.Attempts++
+	if task.Exhausted() {
+		// Replace task if user used all attempts
+		task = b.newTask(chatID, user.ID)
+	} else {
+		err := b.DB.UpdateChatUserTask(chatID, user.ID, task)
+		if err != nil {
+			b.Log.Errorf("%+v", errors.Wrapf(err, "Failed save attempts of user %s", names.ShortUserName(user)))
+		}
+	}
+	return b.sendTask(chatID, user, task, true)
+}
+
+// startTask issues new task of chat to user and remembers chat for answers in private chat
+func (b *Bot) startTask(chatID int64, user *tg.User) error {
+	err := b.DB.SetActiveChat(user.ID, chatID)
+	if err != nil {
+		return errors.Wrapf(err, "Failed set active chat of user %s", names.ShortUserName(user))
+	}
+	task := b.newTask(chatID, user.ID)
+	return b.sendTask(chatID, user, task, false)
+}
+
+// resendTask makes chat active and sends task which was already issued to user
+func (b *Bot) resendTask(chatID int64, user *tg.User) error {
+	err := b.DB.SetActiveChat(user.ID, chatID)
+	if err != nil {
+		return errors.Wrapf(err, "Failed set active chat of user %s", names.ShortUserName(user))
+	}
+	return b.sendTask(chatID, user, b.pendingTask(chatID, user.ID), false)
+}
+
+// offerNextChat issues task of next chat where user is not confirmed yet
+func (b *Bot) offerNextChat(user *tg.User) error {
+	u, err := b.DB.GetUser(user.ID)
+	if err != nil {
+		return errors.Wrapf(err, "Failed get user info in offerNextChat for %s", names.ShortUserName(user))
+	}
+	if len(u.Chats) == 0 {
+		return nil
+	}
+	chatID := u.Chats[len(u.Chats)-1]
+
+	text := fmt.Sprintf("Вам также нужно пройти тест для чата %s", b.DB.GetChatTitle(chatID))
+	_, err = b.API.Send(tg.NewMessage(int64(user.ID), text))
+	if err != nil {
+		return errors.Wrapf(err, "Error sending next chat message to user %s.", names.ShortUserName(user))
+	}
+	return b.startTask(chatID, user)
+}
+
+// activeChat returns chat which test user passes in private chat with bot
+func activeChat(user config.User) (int64, bool) {
+	for _, id := range user.Chats {
+		if id == user.ActiveChat {
+			return id, true
+		}
+	}
+	if len(user.Chats) == 0 {
+		return 0, false
+	}
+	return user.Chats[len(user.Chats)-1], true
+}
+
+// failUser kicks user which used all attempts to pass the test
+func (b *Bot) failUser(chatID int64, user *tg.User, settings config.ChatSettings) error {
+	untilDate := time.Now().Unix() + settings.Cooldown
+	err := b.kickPending(chatID, user.ID, untilDate)
+	if err != nil {
+		b.notifyAdmins(chatID, fmt.Sprintf("Не удалось удалить пользователя %s из чата %s после %d неверных ответов", names.FullUserName(user), b.DB.GetChatTitle(chatID), settings.MaxAttempts))
+		return err
+	}
+
+	text := fmt.Sprintf("Вы исчерпали попытки и удалены из чата %s. Вы сможете вступить снова через %s.", b.DB.GetChatTitle(chatID), formatDuration(settings.Cooldown))
+	_, err = b.API.Send(tg.NewMessage(int64(user.ID), text))
+	if err != nil {
+		b.Log.Errorf("%+v", errors.Wrapf(err, "Error sending message to failed user %s.", names.ShortUserName(user)))
+	}
+
+	b.notifyAdmins(chatID, fmt.Sprintf("Пользователь %s удалён из чата %s после %d неверных ответов", names.FullUserName(user), b.DB.GetChatTitle(chatID), settings.MaxAttempts))
+	b.Log.Infof("User %s was kicked from chat %d after %d wrong answers", names.ShortUserName(user), chatID, settings.MaxAttempts)
+	return nil
+}
+
+// sendTask sends question of task to user in private chat
+func (b *Bot) sendTask(chatID int64, user *tg.User, task challenge.Task, isInvalid bool) error {
+	msg := b.TGMessageQuestion(chatID, int64(user.ID), task)
+	if isInvalid {
+		msg = b.TGMessageInvalid(chatID, int64(user.ID), task)
+	}
+
+	var err error
+	if imager, ok := challenge.Get(task.Kind).(challenge.Imager); ok {
+		var data []byte
+		data, err = imager.Image(task)
+		if err != nil {
+			return errors.Wrapf(err, "Error render image of task for user %s.", names.ShortUserName(user))
+		}
+		photo := tg.NewPhotoUpload(msg.ChatID, tg.FileBytes{Name: task.Kind + ".png", Bytes: data})
+		photo.Caption = msg.Text
+		photo.ReplyMarkup = msg.ReplyMarkup
+		_, err = b.API.Send(photo)
+	} else {
+		_, err = b.API.Send(msg)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Error sending task to user %s.", names.ShortUserName(user))
+	}
+	return nil
+}
+
+// answerButtonCallback handles pressing of button with answer variant
+func (b *Bot) answerButtonCallback(query *tg.CallbackQuery, args []string) (callbackAnswer, error) {
+	var answer callbackAnswer
+	if len(args) < 2 {
+		return answer, fmt.Errorf("Invalid answer data %s", query.Data)
+	}
+	chatID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return answer, errors.Wrap(err, "Error parse chatID in answerButtonCallback")
+	}
+	i, err := strconv.Atoi(args[1])
+	if err != nil {
+		return answer, errors.Wrap(err, "Error parse option in answerButtonCallback")
+	}
+
+	cu, err := b.DB.GetChatUser(chatID, query.From.ID)
+	if err != nil || cu.Confirmed || i < 0 || i >= len(cu.Task.Options) {
+		return callbackAnswer{Text: "Кнопка устарела", Alert: true}, nil
+	}
+
+	// Remove buttons from answered question
+	if query.Message != nil {
+		edit := tg.NewEditMessageReplyMarkup(query.Message.Chat.ID, query.Message.MessageID, tg.InlineKeyboardMarkup{
+			InlineKeyboard: [][]tg.InlineKeyboardButton{},
+		})
+		_, err := b.API.Send(edit)
+		if err != nil {
+			b.Log.Errorf("%+v", errors.Wrap(err, "Error remove answer buttons"))
+		}
+	}
+
+	return answer, b.verifyAnswer(chatID, query.From, cu.Task.Options[i])
+}
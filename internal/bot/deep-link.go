@@ -0,0 +1,28 @@
+package bot
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"tg-group-control-bot/internal/token"
+)
+
+// linkSecret returns key for signing deep links. Bot token is used if secret is not configured.
+func (b *Bot) linkSecret() []byte {
+	if b.Config.LinkSecret != "" {
+		return []byte(b.Config.LinkSecret)
+	}
+	sum := sha256.Sum256([]byte(b.Config.BotToken))
+	return sum[:]
+}
+
+// testLink returns deep link to the test in private chat with bot for user of chat
+func (b *Bot) testLink(chatID int64, userID int, timeout int64) string {
+	t := token.Sign(b.linkSecret(), token.Payload{
+		ChatID:  chatID,
+		UserID:  userID,
+		Expires: time.Now().Unix() + timeout,
+	})
+	return fmt.Sprintf("tg://resolve?domain=%s&start=%s", b.API.Self.UserName, t)
+}
@@ -0,0 +1,426 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"tg-group-control-bot/internal/config"
+	"tg-group-control-bot/internal/names"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/pkg/errors"
+)
+
+// Federation is created by owner in private chat with /newfed. Chat admin asks to join
+// federation with /joinfed in group chat and owner approves request with inline button.
+// Callback data of request buttons is fed:<approve|reject>:<federationID>:<chatID>
+const fedPrefix = "fed"
+
+// newFedCommand handles /newfed <name>
+func (b *Bot) newFedCommand(message *tg.Message) error {
+	if !message.Chat.IsPrivate() {
+		return b.replyPrivate(message, "Создайте федерацию в личном чате с ботом: /newfed <название>")
+	}
+	name := strings.TrimSpace(message.CommandArguments())
+	if name == "" {
+		return b.replyPrivate(message, "Используйте: /newfed <название>")
+	}
+
+	id, err := newNonce()
+	if err != nil {
+		return errors.Wrap(err, "Failed generate federation ID")
+	}
+	err = b.DB.CreateFederation(config.Federation{
+		ID:      id,
+		Name:    name,
+		OwnerID: message.From.ID,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Failed create federation %s", name)
+	}
+	b.Log.Infof("User %s created federation %s `%s`", names.ShortUserName(message.From), id, name)
+
+	return b.replyPrivate(message, fmt.Sprintf("Федерация «%s» создана.\nID: %s\nЧтобы добавить чат, администратор чата отправляет в группе /joinfed %s", name, id, id))
+}
+
+// joinFedCommand handles /joinfed <federationID> sent by chat admin to group chat
+func (b *Bot) joinFedCommand(message *tg.Message) error {
+	if !b.groupAdminAllowed(message) {
+		return nil
+	}
+	id := strings.TrimSpace(message.CommandArguments())
+	if id == "" {
+		return b.replyGroup(message, "Используйте: /joinfed <ID федерации>")
+	}
+	fed, err := b.DB.GetFederation(id)
+	if err != nil {
+		b.Log.Warnf("Federation %s requested by %s is not found. %v", id, names.ShortUserName(message.From), err)
+		return b.replyGroup(message, "Федерация не найдена")
+	}
+	for _, chatID := range fed.Chats {
+		if chatID == message.Chat.ID {
+			return b.replyGroup(message, fmt.Sprintf("Чат уже состоит в федерации «%s»", fed.Name))
+		}
+	}
+
+	text := fmt.Sprintf("Администратор %s просит добавить чат %s в федерацию «%s»", names.FullUserName(message.From), names.ChatName(message.Chat), fed.Name)
+	msg := tg.NewMessage(int64(fed.OwnerID), text)
+	msg.ReplyMarkup = tg.NewInlineKeyboardMarkup(tg.NewInlineKeyboardRow(
+		tg.NewInlineKeyboardButtonData("Принять", callbackData(fedPrefix, "approve", fed.ID, message.Chat.ID)),
+		tg.NewInlineKeyboardButtonData("Отклонить", callbackData(fedPrefix, "reject", fed.ID, message.Chat.ID)),
+	))
+	_, err = b.API.Send(msg)
+	if err != nil {
+		b.Log.Errorf("%+v", errors.Wrapf(err, "Error sending join request to owner of federation %s", fed.ID))
+		return b.replyGroup(message, "Не удалось отправить запрос владельцу федерации. Владелец должен начать чат с ботом.")
+	}
+	return b.replyGroup(message, fmt.Sprintf("Запрос на вступление в федерацию «%s» отправлен владельцу", fed.Name))
+}
+
+// leaveFedCommand handles /leavefed sent by chat admin to group chat
+func (b *Bot) leaveFedCommand(message *tg.Message) error {
+	if !b.groupAdminAllowed(message) {
+		return nil
+	}
+	fed, err := b.DB.GetChatFederation(message.Chat.ID)
+	if err != nil {
+		return b.replyGroup(message, "Чат не состоит в федерации")
+	}
+	err = b.DB.RemoveFederationChat(fed.ID, message.Chat.ID)
+	if err != nil {
+		return errors.Wrapf(err, "Failed remove chat %s from federation %s", names.ChatName(message.Chat), fed.ID)
+	}
+	b.sendPrivate(fed.OwnerID, fmt.Sprintf("Чат %s покинул федерацию «%s»", names.ChatName(message.Chat), fed.Name))
+	return b.replyGroup(message, fmt.Sprintf("Чат покинул федерацию «%s»", fed.Name))
+}
+
+// fedInfoCommand handles /fedinfo. Shows federation of group chat or federations of owner in private chat.
+func (b *Bot) fedInfoCommand(message *tg.Message) error {
+	if !message.Chat.IsPrivate() {
+		fed, err := b.DB.GetChatFederation(message.Chat.ID)
+		if err != nil {
+			return b.replyGroup(message, "Чат не состоит в федерации")
+		}
+		return b.replyGroup(message, fmt.Sprintf("Чат состоит в федерации «%s», чатов: %d, банов: %d", fed.Name, len(fed.Chats), len(fed.Bans)))
+	}
+
+	feds, err := b.DB.GetOwnerFederations(message.From.ID)
+	if err != nil {
+		return errors.Wrapf(err, "Failed get federations of %s", names.ShortUserName(message.From))
+	}
+	if len(feds) == 0 {
+		return b.replyPrivate(message, "У вас нет федераций. Создайте её командой /newfed <название>")
+	}
+
+	text := make([]string, 0, len(feds))
+	for _, fed := range feds {
+		lines := []string{fmt.Sprintf("«%s» ID: %s", fed.Name, fed.ID)}
+		trust := "нет"
+		if fed.TrustVerified {
+			trust = "да"
+		}
+		lines = append(lines, fmt.Sprintf("Доверять проверке в других чатах: %s", trust))
+		lines = append(lines, fmt.Sprintf("Банов: %d", len(fed.Bans)))
+		for _, chatID := range fed.Chats {
+			lines = append(lines, fmt.Sprintf("%d %s", chatID, b.DB.GetChatTitle(chatID)))
+		}
+		text = append(text, strings.Join(lines, "\n"))
+	}
+	return b.replyPrivate(message, strings.Join(text, "\n\n"))
+}
+
+// fedTrustCommand handles /fedtrust <federationID> <on|off> sent by owner
+func (b *Bot) fedTrustCommand(message *tg.Message) error {
+	args := strings.Fields(message.CommandArguments())
+	if len(args) != 2 || (args[1] != "on" && args[1] != "off") {
+		return b.replyPrivate(message, "Используйте: /fedtrust <ID федерации> on|off")
+	}
+	fed, ok := b.ownedFederation(message, args[0])
+	if !ok {
+		return nil
+	}
+
+	trust := args[1] == "on"
+	err := b.DB.SetFederationTrust(fed.ID, trust)
+	if err != nil {
+		return errors.Wrapf(err, "Failed change trust of federation %s", fed.ID)
+	}
+	if trust {
+		return b.replyPrivate(message, fmt.Sprintf("Пользователи, прошедшие тест в одном чате федерации «%s», будут подтверждаться в остальных автоматически", fed.Name))
+	}
+	return b.replyPrivate(message, fmt.Sprintf("Пользователи будут проходить тест в каждом чате федерации «%s»", fed.Name))
+}
+
+// fedRemoveCommand handles /fedremove <federationID> <chatID> sent by owner
+func (b *Bot) fedRemoveCommand(message *tg.Message) error {
+	args := strings.Fields(message.CommandArguments())
+	if len(args) != 2 {
+		return b.replyPrivate(message, "Используйте: /fedremove <ID федерации> <ID чата>")
+	}
+	chatID, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return b.replyPrivate(message, "Используйте: /fedremove <ID федерации> <ID чата>")
+	}
+	fed, ok := b.ownedFederation(message, args[0])
+	if !ok {
+		return nil
+	}
+
+	err = b.DB.RemoveFederationChat(fed.ID, chatID)
+	if err != nil {
+		return errors.Wrapf(err, "Failed remove chat %d from federation %s", chatID, fed.ID)
+	}
+	b.notifyAdmins(chatID, fmt.Sprintf("Чат %s исключён из федерации «%s»", b.DB.GetChatTitle(chatID), fed.Name))
+	return b.replyPrivate(message, fmt.Sprintf("Чат %d исключён из федерации «%s»", chatID, fed.Name))
+}
+
+// fedBanCommand handles /fedban <userID> [reason] sent by chat admin to group chat.
+// Replying to message of user with /fedban [reason] bans author of message.
+func (b *Bot) fedBanCommand(message *tg.Message) error {
+	if !b.groupAdminAllowed(message) {
+		return nil
+	}
+	fed, err := b.DB.GetChatFederation(message.Chat.ID)
+	if err != nil {
+		return b.replyGroup(message, "Чат не состоит в федерации")
+	}
+
+	userID, reason, ok := fedBanTarget(message)
+	if !ok {
+		return b.replyGroup(message, "Используйте: /fedban <ID пользователя> [причина] или ответьте командой на сообщение пользователя")
+	}
+	if b.isChatAdmin(message.Chat.ID, userID) {
+		return b.replyGroup(message, "Нельзя забанить администратора")
+	}
+
+	failed, err := b.fedBan(fed, message.Chat.ID, userID, reason)
+	if err != nil {
+		return err
+	}
+	b.Log.Infof("User %d was banned in federation %s by %s with reason `%s`", userID, fed.ID, names.ShortUserName(message.From), reason)
+	return b.replyGroup(message, fmt.Sprintf("Пользователь %d забанен во всех чатах федерации «%s». Ошибок: %d", userID, fed.Name, failed))
+}
+
+// fedUnbanCommand handles /fedunban <userID> sent by chat admin to group chat
+func (b *Bot) fedUnbanCommand(message *tg.Message) error {
+	if !b.groupAdminAllowed(message) {
+		return nil
+	}
+	fed, err := b.DB.GetChatFederation(message.Chat.ID)
+	if err != nil {
+		return b.replyGroup(message, "Чат не состоит в федерации")
+	}
+	userID, err := strconv.Atoi(strings.TrimSpace(message.CommandArguments()))
+	if err != nil {
+		return b.replyGroup(message, "Используйте: /fedunban <ID пользователя>")
+	}
+
+	err = b.DB.RemoveFederationBan(fed.ID, userID)
+	if err != nil {
+		return errors.Wrapf(err, "Failed unban user %d in federation %s", userID, fed.ID)
+	}
+	failed := 0
+	for _, chatID := range fed.Chats {
+		resp, err := b.API.UnbanChatMember(tg.ChatMemberConfig{
+			ChatID: chatID,
+			UserID: userID,
+		})
+		if err != nil {
+			b.Log.Errorf("Failed unban user %d in chat %d with code %d and error %s", userID, chatID, resp.ErrorCode, resp.Description)
+			failed++
+		}
+	}
+	b.Log.Infof("User %d was unbanned in federation %s by %s", userID, fed.ID, names.ShortUserName(message.From))
+	return b.replyGroup(message, fmt.Sprintf("Пользователь %d разбанен во всех чатах федерации «%s». Ошибок: %d", userID, fed.Name, failed))
+}
+
+// fedBan saves ban in federation and bans user in all chats of federation.
+// Returns number of chats where user was not banned.
+func (b *Bot) fedBan(fed config.Federation, chatID int64, userID int, reason string) (int, error) {
+	err := b.DB.AddFederationBan(fed.ID, config.FederationBan{
+		UserID: userID,
+		ChatID: chatID,
+		Reason: reason,
+		Date:   time.Now().Unix(),
+	})
+	if err != nil {
+		return 0, errors.Wrapf(err, "Failed ban user %d in federation %s", userID, fed.ID)
+	}
+
+	failed := 0
+	for _, id := range fed.Chats {
+		if err := b.banSpammer(id, userID); err != nil {
+			b.Log.Errorf("%+v", err)
+			failed++
+		}
+	}
+	return failed, nil
+}
+
+// propagateBan bans user in other chats of federation after ban in chat
+func (b *Bot) propagateBan(chatID int64, userID int, reason string) {
+	fed, err := b.DB.GetChatFederation(chatID)
+	if err != nil {
+		return
+	}
+	if _, err := b.fedBan(fed, chatID, userID, reason); err != nil {
+		b.Log.Errorf("%+v", err)
+	}
+}
+
+// kickFederationBanned kicks new members which are banned in federation of chat and adds them to banned
+func (b *Bot) kickFederationBanned(message *tg.Message, banned map[int]bool) {
+	fed, err := b.DB.GetChatFederation(message.Chat.ID)
+	if err != nil {
+		return
+	}
+	for _, u := range *message.NewChatMembers {
+		if fed.Banned(u.ID) == nil {
+			continue
+		}
+		banned[u.ID] = true
+		if err := b.banSpammer(message.Chat.ID, u.ID); err != nil {
+			b.Log.Errorf("%+v", err)
+			continue
+		}
+		b.Log.Infof("User %s banned in federation %s was kicked from chat %s", names.FullUserName(&u), fed.ID, names.ChatName(message.Chat))
+	}
+}
+
+// federationTrusted checks that user passed the test in other chat of federation which trusts verification
+func (b *Bot) federationTrusted(chatID int64, userID int) bool {
+	fed, err := b.DB.GetChatFederation(chatID)
+	if err != nil || !fed.TrustVerified {
+		return false
+	}
+
+	others := make([]int64, 0, len(fed.Chats))
+	for _, id := range fed.Chats {
+		if id != chatID {
+			others = append(others, id)
+		}
+	}
+	if len(others) == 0 {
+		return false
+	}
+
+	trusted, err := b.DB.UserConfirmedInChats(others, userID)
+	if err != nil {
+		b.Log.Errorf("%+v", err)
+		return false
+	}
+	return trusted
+}
+
+// fedAuth allows to answer join request only to owner of federation
+func (b *Bot) fedAuth(query *tg.CallbackQuery, args []string) bool {
+	if len(args) < 3 {
+		return false
+	}
+	fed, err := b.DB.GetFederation(args[1])
+	if err != nil {
+		return false
+	}
+	return fed.OwnerID == query.From.ID
+}
+
+// fedCallback approves or rejects request of chat to join federation
+func (b *Bot) fedCallback(query *tg.CallbackQuery, args []string) (callbackAnswer, error) {
+	fed, err := b.DB.GetFederation(args[1])
+	if err != nil {
+		return callbackAnswer{}, errors.Wrapf(err, "Failed get federation %s", args[1])
+	}
+	chatID, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return callbackAnswer{}, errors.Wrapf(err, "Invalid chat in callback %s", query.Data)
+	}
+	title := b.DB.GetChatTitle(chatID)
+
+	var text string
+	switch args[0] {
+	case "approve":
+		err = b.DB.AddFederationChat(fed.ID, chatID)
+		if err != nil {
+			return callbackAnswer{}, errors.Wrapf(err, "Failed add chat %d to federation %s", chatID, fed.ID)
+		}
+		b.Log.Infof("Chat %d joined federation %s", chatID, fed.ID)
+		b.notifyAdmins(chatID, fmt.Sprintf("Чат %s принят в федерацию «%s»", title, fed.Name))
+		text = fmt.Sprintf("Чат %s принят в федерацию «%s»", title, fed.Name)
+	case "reject":
+		b.notifyAdmins(chatID, fmt.Sprintf("Владелец федерации «%s» отклонил запрос чата %s", fed.Name, title))
+		text = fmt.Sprintf("Запрос чата %s в федерацию «%s» отклонён", title, fed.Name)
+	default:
+		return callbackAnswer{}, fmt.Errorf("Unknown federation action %s", args[0])
+	}
+
+	_, err = b.API.Send(tg.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, text))
+	if err != nil {
+		b.Log.Errorf("%+v", errors.Wrap(err, "Error edit federation request"))
+	}
+	return callbackAnswer{Text: "Готово"}, nil
+}
+
+// ownedFederation returns federation if sender of message owns it
+func (b *Bot) ownedFederation(message *tg.Message, id string) (config.Federation, bool) {
+	fed, err := b.DB.GetFederation(id)
+	if err != nil || fed.OwnerID != message.From.ID {
+		if err := b.replyPrivate(message, "Федерация не найдена"); err != nil {
+			b.Log.Errorf("%+v", err)
+		}
+		return fed, false
+	}
+	return fed, true
+}
+
+// groupAdminAllowed checks that command was sent by admin to group chat
+func (b *Bot) groupAdminAllowed(message *tg.Message) bool {
+	if message.Chat.IsPrivate() {
+		if err := b.replyPrivate(message, "Отправьте эту команду в группу"); err != nil {
+			b.Log.Errorf("%+v", err)
+		}
+		return false
+	}
+	if !b.isChatAdmin(message.Chat.ID, message.From.ID) {
+		b.Log.Warnf("User %s is not admin of chat %s and cannot use command %s", names.ShortUserName(message.From), names.ChatName(message.Chat), message.Command())
+		return false
+	}
+	return true
+}
+
+// fedBanTarget returns user and reason of ban from arguments or replied message
+func fedBanTarget(message *tg.Message) (int, string, bool) {
+	args := strings.TrimSpace(message.CommandArguments())
+	if message.ReplyToMessage != nil && message.ReplyToMessage.From != nil {
+		return message.ReplyToMessage.From.ID, args, true
+	}
+
+	parts := strings.SplitN(args, " ", 2)
+	userID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, "", false
+	}
+	reason := ""
+	if len(parts) > 1 {
+		reason = strings.TrimSpace(parts[1])
+	}
+	return userID, reason, true
+}
+
+// replyGroup sends text to chat where message was sent
+func (b *Bot) replyGroup(message *tg.Message, text string) error {
+	_, err := b.API.Send(tg.NewMessage(message.Chat.ID, text))
+	if err != nil {
+		return errors.Wrapf(err, "Error sending message to chat %s.", names.ChatName(message.Chat))
+	}
+	return nil
+}
+
+// sendPrivate sends text to private chat with user
+func (b *Bot) sendPrivate(userID int, text string) {
+	_, err := b.API.Send(tg.NewMessage(int64(userID), text))
+	if err != nil {
+		b.Log.Errorf("%+v", errors.Wrapf(err, "Error sending private message to user %d.", userID))
+	}
+}
@@ -0,0 +1,158 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"tg-group-control-bot/internal/names"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/pkg/errors"
+)
+
+// isRoot checks that user is owner of bot
+func (b *Bot) isRoot(userID int) bool {
+	return b.Config.RootUserID != 0 && b.Config.RootUserID == userID
+}
+
+// gbanCommand handles /gban <userID> [reason]
+func (b *Bot) gbanCommand(message *tg.Message) error {
+	if !b.isRoot(message.From.ID) {
+		return b.defaultCommand(message)
+	}
+
+	args := strings.SplitN(strings.TrimSpace(message.CommandArguments()), " ", 2)
+	userID, err := strconv.Atoi(args[0])
+	if err != nil {
+		return b.replyPrivate(message, "Используйте: /gban <ID пользователя> [причина]")
+	}
+	reason := ""
+	if len(args) > 1 {
+		reason = strings.TrimSpace(args[1])
+	}
+
+	err = b.DB.SetUserBan(userID, true, reason)
+	if err != nil {
+		return errors.Wrapf(err, "Failed ban user %d", userID)
+	}
+	// Memoized user is not banned
+	b.Memo.Delete(userID)
+
+	failed := b.forEachChat(func(chatID int64) error {
+		resp, err := b.API.KickChatMember(tg.KickChatMemberConfig{
+			ChatMemberConfig: tg.ChatMemberConfig{
+				ChatID: chatID,
+				UserID: userID,
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("Failed ban user %d in chat %d with code %d and error %s", userID, chatID, resp.ErrorCode, resp.Description)
+		}
+		return b.DB.RemoveChatUser(chatID, userID)
+	})
+	b.Log.Infof("User %d was banned in all chats by %s with reason `%s`", userID, names.ShortUserName(message.From), reason)
+
+	return b.replyPrivate(message, fmt.Sprintf("Пользователь %d забанен во всех чатах. Ошибок: %d", userID, failed))
+}
+
+// ungbanCommand handles /ungban <userID>
+func (b *Bot) ungbanCommand(message *tg.Message) error {
+	if !b.isRoot(message.From.ID) {
+		return b.defaultCommand(message)
+	}
+
+	userID, err := strconv.Atoi(strings.TrimSpace(message.CommandArguments()))
+	if err != nil {
+		return b.replyPrivate(message, "Используйте: /ungban <ID пользователя>")
+	}
+
+	err = b.DB.SetUserBan(userID, false, "")
+	if err != nil {
+		return errors.Wrapf(err, "Failed unban user %d", userID)
+	}
+	b.Memo.Delete(userID)
+
+	failed := b.forEachChat(func(chatID int64) error {
+		resp, err := b.API.UnbanChatMember(tg.ChatMemberConfig{
+			ChatID: chatID,
+			UserID: userID,
+		})
+		if err != nil {
+			return fmt.Errorf("Failed unban user %d in chat %d with code %d and error %s", userID, chatID, resp.ErrorCode, resp.Description)
+		}
+		return nil
+	})
+	b.Log.Infof("User %d was unbanned in all chats by %s", userID, names.ShortUserName(message.From))
+
+	return b.replyPrivate(message, fmt.Sprintf("Пользователь %d разбанен во всех чатах. Ошибок: %d", userID, failed))
+}
+
+// gbanListCommand handles /gbanlist
+func (b *Bot) gbanListCommand(message *tg.Message) error {
+	if !b.isRoot(message.From.ID) {
+		return b.defaultCommand(message)
+	}
+
+	users, err := b.DB.GetBannedUsers()
+	if err != nil {
+		return errors.Wrap(err, "Failed get banned users")
+	}
+	if len(users) == 0 {
+		return b.replyPrivate(message, "Список банов пуст")
+	}
+
+	text := []string{"Забанены во всех чатах:"}
+	for _, u := range users {
+		line := fmt.Sprintf("%d %s — %s", u.ID, u.String(), time.Unix(u.BanDate, 0).Format("2006-01-02 15:04"))
+		if u.BanReason != "" {
+			line += ", " + u.BanReason
+		}
+		text = append(text, line)
+	}
+	return b.replyPrivate(message, strings.Join(text, "\n"))
+}
+
+// kickGlobalBanned kicks new members which are banned in all chats and returns them.
+// Returned users are banned even if kick failed, so they must not be handled as new members.
+func (b *Bot) kickGlobalBanned(message *tg.Message) map[int]bool {
+	banned := make(map[int]bool)
+	for _, u := range *message.NewChatMembers {
+		user, err := b.DB.GetUser(u.ID)
+		if err != nil || !user.Banned {
+			continue
+		}
+		banned[u.ID] = true
+		resp, err := b.API.KickChatMember(tg.KickChatMemberConfig{
+			ChatMemberConfig: tg.ChatMemberConfig{
+				ChatID: message.Chat.ID,
+				UserID: u.ID,
+			},
+		})
+		if err != nil {
+			b.Log.Errorf("Failed kick banned user %s from chat %s with code %d and error %s", names.FullUserName(&u), names.ChatName(message.Chat), resp.ErrorCode, resp.Description)
+			continue
+		}
+		b.Log.Infof("Banned user %s was kicked from chat %s", names.FullUserName(&u), names.ChatName(message.Chat))
+	}
+	return banned
+}
+
+// forEachChat calls function for all chats and returns number of failed calls
+func (b *Bot) forEachChat(f func(chatID int64) error) int {
+	ids, err := b.DB.GetChatIDs()
+	if err != nil {
+		b.Log.Errorf("%+v", err)
+		return 0
+	}
+
+	failed := 0
+	for _, id := range ids {
+		if err := f(id); err != nil {
+			b.Log.Errorf("%+v", err)
+			failed++
+		}
+	}
+	return failed
+}
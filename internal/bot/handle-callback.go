@@ -0,0 +1,114 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"tg-group-control-bot/internal/names"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/pkg/errors"
+)
+
+// callbackAnswer is shown to user after pressing the button
+type callbackAnswer struct {
+	Text  string
+	Alert bool
+	// URL is opened by telegram instead of showing text, only links to the bot are allowed
+	URL string
+}
+
+// callbackRoute describes handling of callback data namespace
+type callbackRoute struct {
+	// Auth checks that user may press the button
+	Auth func(query *tg.CallbackQuery, args []string) bool
+	// Handle executes action of the button
+	Handle func(query *tg.CallbackQuery, args []string) (callbackAnswer, error)
+}
+
+// HandleCallback start handling callback queries from inline buttons.
+// Callback data has format <namespace>:<arg>:<arg>...
+func (b *Bot) HandleCallback(query *tg.CallbackQuery) error {
+	namespace, args := parseCallbackData(query.Data)
+
+	route, exist := b.callbackRoute(namespace)
+	if !exist {
+		b.answerCallback(query, callbackAnswer{Text: "Кнопка устарела", Alert: true})
+		return fmt.Errorf("Unsupported callback %s", query.Data)
+	}
+
+	if route.Auth != nil && !route.Auth(query, args) {
+		b.answerCallback(query, callbackAnswer{Text: "Эта кнопка не для вас", Alert: true})
+		return fmt.Errorf("User %s is not allowed to press button %s", names.ShortUserName(query.From), query.Data)
+	}
+
+	answer, err := route.Handle(query, args)
+	if err != nil {
+		if answer.Text == "" {
+			answer = callbackAnswer{Text: "Произошла ошибка, попробуйте позже", Alert: true}
+		}
+		b.answerCallback(query, answer)
+		return err
+	}
+	b.answerCallback(query, answer)
+	return nil
+}
+
+func (b *Bot) callbackRoute(namespace string) (callbackRoute, bool) {
+	switch namespace {
+	case settingsPrefix:
+		return callbackRoute{Auth: b.settingsAuth, Handle: b.settingsCallback}, true
+	case answerPrefix:
+		return callbackRoute{Handle: b.answerButtonCallback}, true
+	case verifyPrefix:
+		return callbackRoute{Auth: b.verifyAuth, Handle: b.verifyCallback}, true
+	case approvalPrefix:
+		return callbackRoute{Auth: b.authChatAdmin(1), Handle: b.approvalCallback}, true
+	case fedPrefix:
+		return callbackRoute{Auth: b.fedAuth, Handle: b.fedCallback}, true
+	default:
+		return callbackRoute{}, false
+	}
+}
+
+// answerCallback stops loading animation on button and shows answer to user
+func (b *Bot) answerCallback(query *tg.CallbackQuery, answer callbackAnswer) {
+	config := tg.NewCallback(query.ID, answer.Text)
+	config.ShowAlert = answer.Alert
+	config.URL = answer.URL
+	_, err := b.API.AnswerCallbackQuery(config)
+	if err != nil {
+		b.Log.Errorf("%+v", errors.Wrapf(err, "Error answer callback query %s", query.Data))
+	}
+}
+
+// authChatAdmin returns checker of admin rights in chat passed in argument on position pos
+func (b *Bot) authChatAdmin(pos int) func(*tg.CallbackQuery, []string) bool {
+	return func(query *tg.CallbackQuery, args []string) bool {
+		if len(args) <= pos {
+			return false
+		}
+		chatID, err := strconv.ParseInt(args[pos], 10, 64)
+		if err != nil {
+			return false
+		}
+		return b.isChatAdmin(chatID, query.From.ID)
+	}
+}
+
+// callbackData joins namespace and arguments to callback data of button.
+// Telegram limits callback data to 64 bytes.
+func callbackData(namespace string, args ...interface{}) string {
+	parts := make([]string, 0, len(args)+1)
+	parts = append(parts, namespace)
+	for _, arg := range args {
+		parts = append(parts, fmt.Sprint(arg))
+	}
+	return strings.Join(parts, ":")
+}
+
+func parseCallbackData(data string) (string, []string) {
+	parts := strings.Split(data, ":")
+	return parts[0], parts[1:]
+}
@@ -1,9 +1,10 @@
 package bot
 
 import (
-	"strconv"
+	"time"
 
 	"tg-group-control-bot/internal/names"
+	"tg-group-control-bot/internal/token"
 
 	tg "github.com/go-telegram-bot-api/telegram-bot-api"
 	"github.com/pkg/errors"
@@ -14,20 +15,97 @@ func (b *Bot) HandleCommand(message *tg.Message) error {
 	switch message.Command() {
 	case "start":
 		return b.askQuestion(message)
+	case "settings":
+		return b.settingsCommand(message)
+	case "reload":
+		return b.reloadCommand(message)
+	case "sweep":
+		return b.sweepCommand(message)
+	case "gban":
+		return b.gbanCommand(message)
+	case "ungban":
+		return b.ungbanCommand(message)
+	case "gbanlist":
+		return b.gbanListCommand(message)
+	case "newfed":
+		return b.newFedCommand(message)
+	case "joinfed":
+		return b.joinFedCommand(message)
+	case "leavefed":
+		return b.leaveFedCommand(message)
+	case "fedinfo":
+		return b.fedInfoCommand(message)
+	case "fedtrust":
+		return b.fedTrustCommand(message)
+	case "fedremove":
+		return b.fedRemoveCommand(message)
+	case "fedban":
+		return b.fedBanCommand(message)
+	case "fedunban":
+		return b.fedUnbanCommand(message)
+	case "trust":
+		return b.trustCommand(message, true)
+	case "untrust":
+		return b.trustCommand(message, false)
+	case "allowbot":
+		return b.allowBotCommand(message, true)
+	case "denybot":
+		return b.allowBotCommand(message, false)
+	case "lockdown":
+		return b.lockdownCommand(message)
+	case "history":
+		return b.historyCommand(message)
+	case "addquestion":
+		return b.addQuestionCommand(message)
+	case "questions":
+		return b.questionsCommand(message)
+	case "delquestion":
+		return b.delQuestionCommand(message)
 	default:
 		return b.defaultCommand(message)
 	}
 }
 
 func (b *Bot) askQuestion(message *tg.Message) error {
-	chatID, err := strconv.ParseInt(message.CommandArguments(), 10, 64)
+	if message.CommandArguments() == "" {
+		return b.replyPrivate(message, "Я защищаю группы от ботов. Чтобы пройти тест, нажмите кнопку под приветствием в чате, в который вы вступили.")
+	}
+
+	p, err := token.Verify(b.linkSecret(), message.CommandArguments(), time.Now().Unix())
+	switch {
+	case err == token.ErrExpired:
+		return b.replyPrivate(message, "Ссылка на тест устарела.")
+	case err != nil:
+		b.Log.Warnf("User %s sent invalid start payload %s", names.ShortUserName(message.From), message.CommandArguments())
+		return b.replyPrivate(message, "Ссылка на тест недействительна. Нажмите кнопку под приветствием в чате.")
+	case p.UserID != message.From.ID:
+		return b.replyPrivate(message, "Эта ссылка предназначена другому пользователю. Нажмите кнопку под своим приветствием в чате.")
+	}
+
+	cu, err := b.DB.GetChatUser(p.ChatID, message.From.ID)
+	if err != nil {
+		b.Log.Warnf("User %s is not found in chat %d. %v", names.ShortUserName(message.From), p.ChatID, err)
+		return b.replyPrivate(message, "Вы не ожидаете подтверждения в этом чате.")
+	}
+	if cu.Confirmed {
+		return b.replyPrivate(message, "Вы уже прошли тест для этого чата.")
+	}
+	if cu.LinkUsed {
+		// User may have lost the question, the same one is sent again so it can't be rerolled
+		err = b.resendTask(p.ChatID, message.From)
+		if err != nil {
+			return errors.Wrap(err, "Error resending task in askQuestion")
+		}
+		return nil
+	}
+
+	err = b.DB.SetChatUserLinkUsed(p.ChatID, message.From.ID)
 	if err != nil {
-		return errors.Wrap(err, "Error parse chatID in askQuestion")
+		return errors.Wrap(err, "Error mark link as used in askQuestion")
 	}
-	msg := b.TGMessageQuestion(chatID, message.Chat.ID)
-	_, err = b.API.Send(msg)
+	err = b.startTask(p.ChatID, message.From)
 	if err != nil {
-		return errors.Wrapf(err, "Error sending message in askQuestion to user %s.", names.ShortUserName(message.From))
+		return errors.Wrap(err, "Error sending task in askQuestion")
 	}
 	return nil
 }
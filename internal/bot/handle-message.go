@@ -2,7 +2,7 @@ package bot
 
 import (
 	"fmt"
-	"strings"
+	"time"
 
 	"tg-group-control-bot/internal/config"
 
@@ -15,6 +15,12 @@ import (
 
 // HandleMessage start handling text messages
 func (b *Bot) HandleMessage(message *tg.Message) error {
+	banned := make(map[int]bool)
+	if message.NewChatMembers != nil {
+		banned = b.kickGlobalBanned(message)
+		b.kickFederationBanned(message, banned)
+	}
+
 	// Cancel execution if command from bot or user is banned
 	_, err := b.UserCheck(message.From)
 	if err != nil {
@@ -23,7 +29,7 @@ func (b *Bot) HandleMessage(message *tg.Message) error {
 
 	switch {
 	case message.NewChatMembers != nil:
-		return b.userAddedHandler(message)
+		return b.userAddedHandler(message, banned)
 	case message.LeftChatMember != nil:
 		return b.userLeftHandler(message)
 	default:
@@ -35,84 +41,139 @@ func (b *Bot) textHandler(message *tg.Message) error {
 	// Message to chat with bot
 	if int64(message.From.ID) == message.Chat.ID {
 		b.Log.Infof("Received message in bot chat from user %s with text `%s`", names.ShortUserName(message.From), message.Text)
+		if handled, err := b.settingsInput(message); handled {
+			return err
+		}
 		return b.checkAnswer(message)
 	}
 	b.Log.Infof("Received message in chat from user %s with text `%s`", names.ShortUserName(message.From), message.Text)
-	// TODO Increment counter of user messages in chat
-	return nil
+	return b.countMessage(message)
 }
 
 func (b *Bot) checkAnswer(message *tg.Message) error {
-	_, user, err := b.DB.UserCheck(config.User{ID: message.From.ID})
+	user, err := b.DB.GetUser(message.From.ID)
 	if err != nil {
 		return errors.Wrapf(err, "Failed get user info in checkAnswer for %s", names.ShortUserName(message.From))
 	}
 
-	if len(user.Chats) == 0 {
+	chatID, ok := activeChat(user)
+	if !ok {
 		return fmt.Errorf("No unconfirmed chats for %s", names.ShortUserName(message.From))
 	}
 
-	chatID := user.Chats[len(user.Chats)-1]
+	return b.verifyAnswer(chatID, message.From, message.Text)
+}
 
-	lowerCasedText := strings.ToLower(message.Text)
-	if lowerCasedText == "нет" || lowerCasedText == "no" {
-		var t bool = true
-		// Grant user permissions
-		resp, err := b.API.RestrictChatMember(tg.RestrictChatMemberConfig{
-			ChatMemberConfig: tg.ChatMemberConfig{
-				ChatID: chatID,
-				UserID: message.From.ID,
-			},
-			CanSendMessages:       &t,
-			CanSendMediaMessages:  &t,
-			CanSendOtherMessages:  &t,
-			CanAddWebPagePreviews: &t,
+// confirmUser grants permissions to user which passed the test and cleans up confirmation data
+func (b *Bot) confirmUser(chatID int64, user *tg.User) error {
+	p := b.confirmedPermissions(chatID, user.ID)
+	settings := b.chatSettings(chatID)
+	probation := settings.ProbationMessages > 0
+	if probation {
+		p = p.Intersect(probationPermissions)
+	}
+	// Grant user permissions
+	resp, err := b.API.RestrictChatMember(tg.RestrictChatMemberConfig{
+		ChatMemberConfig: tg.ChatMemberConfig{
+			ChatID: chatID,
+			UserID: user.ID,
+		},
+		CanSendMessages:       &p.CanSendMessages,
+		CanSendMediaMessages:  &p.CanSendMediaMessages,
+		CanSendOtherMessages:  &p.CanSendOtherMessages,
+		CanAddWebPagePreviews: &p.CanAddWebPagePreviews,
+	})
+	if err != nil {
+		// TODO Send error message to admins
+		return fmt.Errorf("Failed restore new user privileges with code %d and error %s", resp.ErrorCode, resp.Description)
+	}
+	ref, err := b.DB.ConfirmChatUser(chatID, user.ID)
+	if err != nil {
+		return errors.Wrapf(err, "Error update user %d in storage for chat %d.", user.ID, chatID)
+	}
+	// Delete confirmation message from group chat
+	if ref.ChatID != 0 {
+		_, err := b.API.DeleteMessage(tg.DeleteMessageConfig{
+			ChatID:    ref.ChatID,
+			MessageID: ref.MsgID,
 		})
 		if err != nil {
+			b.Log.Errorf("Error delete confirmation message from chat %d %v", chatID, err.Error())
 			// TODO Send error message to admins
-			return fmt.Errorf("Failed restore new user privileges with code %d and error %s", resp.ErrorCode, resp.Description)
-		}
-		ref, err := b.DB.ConfirmChatUser(chatID, message.From.ID)
-		if err != nil {
-			return errors.Wrapf(err, "Error update user %d in storage for chat %s.", message.From.ID, names.ChatName(message.Chat))
 		}
-		// Delete confirmation message from group chat
-		if ref.ChatID != 0 {
-			_, err := b.API.DeleteMessage(tg.DeleteMessageConfig{
-				ChatID:    ref.ChatID,
-				MessageID: ref.MsgID,
-			})
-			if err != nil {
-				b.Log.Errorf("Error delete confirmation message from chat %s %v", names.ChatName(message.Chat), err.Error())
-				// TODO Send error message to admins
-			}
+	}
+	// Delete chat from user's unconfirmed chats
+	err = b.DB.DeleteUnconfirmedChat(chatID, user.ID)
+	if err != nil {
+		return errors.Wrapf(err, "Error delete user's(%d %s) unconfirmed chat %d", user.ID, names.ShortUserName(user), chatID)
+	}
+	b.recordEvent(chatID, user.ID, config.MemberVerified, 0)
+	if probation {
+		if err := b.startProbation(chatID, user.ID, settings); err != nil {
+			b.Log.Errorf("%+v", err)
 		}
-		// Delete chat from user's unconfirmed chats
-		err = b.DB.DeleteUnconfirmedChat(chatID, message.From.ID)
+	}
+	err = b.unschedule(jobKickUnconfirmed, chatID, user.ID)
+	if err != nil {
+		b.Log.Errorf("%+v", errors.Wrapf(err, "Error cancel kick of user %s", names.ShortUserName(user)))
+	}
+	return nil
+}
+
+// kickPending kicks user which did not pass the test and cleans up confirmation data.
+// User can join chat again after untilDate, zero untilDate allows to join immediately.
+func (b *Bot) kickPending(chatID int64, userID int, untilDate int64) error {
+	member := tg.ChatMemberConfig{
+		ChatID: chatID,
+		UserID: userID,
+	}
+	resp, err := b.API.KickChatMember(tg.KickChatMemberConfig{
+		ChatMemberConfig: member,
+		UntilDate:        untilDate,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed kick unconfirmed user %d from chat %d with code %d and error %s", userID, chatID, resp.ErrorCode, resp.Description)
+	}
+	if untilDate == 0 {
+		// Kicked user is banned by telegram, unban to allow user join again
+		resp, err = b.API.UnbanChatMember(member)
 		if err != nil {
-			return errors.Wrapf(err, "Error delete user's(%d %s) unconfirmed chat %s %v", message.From.ID, names.ShortUserName(message.From), names.ChatName(message.Chat))
+			b.Log.Errorf("Failed unban kicked user %d in chat %d with code %d and error %s", userID, chatID, resp.ErrorCode, resp.Description)
 		}
-		// Send success message to user in bot chat
-		msg := b.TGMessageSuccess(chatID, message.Chat.ID)
-		_, err = b.API.Send(msg)
+	}
+
+	ref, err := b.DB.RemoveUnconfirmedChatUser(chatID, userID)
+	if err != nil {
+		return errors.Wrapf(err, "Error remove unconfirmed user %d from chat %d", userID, chatID)
+	}
+	if ref.ChatID != 0 {
+		_, err := b.API.DeleteMessage(tg.DeleteMessageConfig{
+			ChatID:    ref.ChatID,
+			MessageID: ref.MsgID,
+		})
 		if err != nil {
-			return errors.Wrapf(err, "Error sending success message in checkAnswer to user %s.", names.ShortUserName(message.From))
+			b.Log.Errorf("Error delete confirmation message from chat %d %v", chatID, err.Error())
 		}
-
-		return nil
 	}
 
-	msg := b.TGMessageInvalid(chatID, message.Chat.ID)
-	_, err = b.API.Send(msg)
+	err = b.DB.DeleteUnconfirmedChat(chatID, userID)
 	if err != nil {
-		return errors.Wrapf(err, "Error sending invalid message in checkAnswer to user %s.", names.ShortUserName(message.From))
+		return errors.Wrapf(err, "Error delete user's %d unconfirmed chat %d", userID, chatID)
+	}
+	err = b.unschedule(jobKickUnconfirmed, chatID, userID)
+	if err != nil {
+		b.Log.Errorf("%+v", errors.Wrapf(err, "Error cancel kick of user %d", userID))
 	}
 	return nil
 }
 
-func (b *Bot) userAddedHandler(message *tg.Message) error {
+// userAddedHandler handles new members of chat except banned ones which were already kicked
+func (b *Bot) userAddedHandler(message *tg.Message, banned map[int]bool) error {
 	for _, u := range *message.NewChatMembers {
-		isNeedMessage := true
+		u := u
+		if banned[u.ID] {
+			continue
+		}
 		if u.ID == b.API.Self.ID {
 			// Bot added to chat
 			b.DB.UpdateChat(config.Chat{
@@ -126,141 +187,231 @@ func (b *Bot) userAddedHandler(message *tg.Message) error {
 					Confirmed: true,
 				}},
 			})
-			isNeedMessage = false
+			// Replace user which added bot with real admins of chat
+			if err := b.syncAdmins(message.Chat.ID); err != nil {
+				b.Log.Errorf("%+v", err)
+			}
+			continue
 		}
 
-		// Stop handling if someone from chat added users
-		// message.From.ID and u.ID must be equal, it means that user was added by itself
+		// Zero inviter means that user joined by itself
+		inviterID := 0
 		if message.From.ID != u.ID {
-			continue
+			inviterID = message.From.ID
 		}
+		b.recordEvent(message.Chat.ID, u.ID, config.MemberJoined, inviterID)
 
-		// If user was add by itself, than slice *message.NewChatMembers contains
-		// only one user and then all "continue" can be replaced with "return error"
-
-		isSpammer := b.SpamCheck(u.ID)
-		b.Log.Errorf("userAddedHandler isSpammer %v", isSpammer)
-
-		if isSpammer {
-			resp, err := b.API.KickChatMember(tg.KickChatMemberConfig{
-				ChatMemberConfig: tg.ChatMemberConfig{
-					ChatID: message.Chat.ID,
-					UserID: u.ID,
-				}})
-
-			if err != nil {
-				errorText := fmt.Sprintf("Failed kick spam user %s from chat %s with code %d and error %s", names.FullUserName(&u), names.ChatName(message.Chat), resp.ErrorCode, resp.Description)
-				b.Log.Errorf("%+v", errors.Wrap(err, errorText))
-
-				// Send message to admins that cannot kick spam user
-				ch, err := b.DB.GetChatInfo(message.Chat.ID)
-				if err == nil {
-					for _, adm := range ch.Admins {
-						msg := tg.NewMessage(int64(adm), errorText)
-						_, err := b.API.Send(msg)
-						if err != nil {
-							b.Log.Errorf("%+v", errors.Wrapf(err, "Error sending message to admin %d in chat %s.", adm, names.ChatName(message.Chat)))
-						}
-					}
-				}
-			}
-			if err == nil {
-				return nil
+		// Bots cannot pass the test
+		if u.IsBot {
+			if err := b.botAdded(message, &u); err != nil {
+				b.Log.Errorf("%+v", err)
 			}
+			continue
 		}
 
-		isConfirmed, err := b.DB.UserConfirmed(message.Chat.ID, u.ID)
-		if err != nil && err.Error() != mongo.ErrNoDocuments.Error() {
-			// continue
-			return errors.Wrap(err, "Failed check user confirmation in userAddedHandler.")
+		// Several users can be added at once, so failure with one of them must not stop others
+		var err error
+		if b.registerJoin(message.Chat.ID) && !b.isChatAdmin(message.Chat.ID, message.From.ID) {
+			err = b.lockdownMember(message, &u, inviterID)
+		} else {
+			err = b.newMember(message, &u, inviterID)
 		}
-		if !isConfirmed {
-			err = b.DB.AddChatUser(message.Chat.ID, config.ChatUser{
-				ID:        u.ID,
-				Confirmed: !isNeedMessage,
-				MsgCount:  0,
-			})
-			if err != nil {
-				// continue
-				return errors.Wrap(err, "Failed add user to chat.")
-			}
-			if isNeedMessage {
-				var f bool = false
-				// Restrict user permissions
-				resp, err := b.API.RestrictChatMember(tg.RestrictChatMemberConfig{
-					ChatMemberConfig: tg.ChatMemberConfig{
-						ChatID: message.Chat.ID,
-						UserID: u.ID,
-					},
-					CanSendMessages:       &f,
-					CanSendMediaMessages:  &f,
-					CanSendOtherMessages:  &f,
-					CanAddWebPagePreviews: &f,
-				})
-				if err != nil {
-					err1 := errors.Wrapf(err, "Failed restrict new user privileges with code %d and error %s", resp.ErrorCode, resp.Description)
-					b.Log.Error(err1)
-
-					// Send message to admins that bot needs to be granted admin privileges
-					ch, err := b.DB.GetChatInfo(message.Chat.ID)
+		if err != nil {
+			b.Log.Errorf("%+v", err)
+		}
+	}
+	return nil
+}
+
+// newMember decides how user which joined chat must be verified
+func (b *Bot) newMember(message *tg.Message, u *tg.User, inviterID int) error {
+	// Verdict of failed check follows spam policy, so fail-closed policy kicks user
+	isSpammer, err := b.SpamCheck(message.Chat.ID, u.ID)
+	if err != nil {
+		b.Log.Errorf("%+v", err)
+	}
+	b.Log.Debugf("newMember user %d isSpammer %v", u.ID, isSpammer)
+
+	if isSpammer {
+		resp, err := b.API.KickChatMember(tg.KickChatMemberConfig{
+			ChatMemberConfig: tg.ChatMemberConfig{
+				ChatID: message.Chat.ID,
+				UserID: u.ID,
+			}})
+
+		if err != nil {
+			errorText := fmt.Sprintf("Failed kick spam user %s from chat %s with code %d and error %s", names.FullUserName(u), names.ChatName(message.Chat), resp.ErrorCode, resp.Description)
+			b.Log.Errorf("%+v", errors.Wrap(err, errorText))
+
+			// Send message to admins that cannot kick spam user
+			ch, err := b.DB.GetChatInfo(message.Chat.ID)
+			if err == nil {
+				for _, adm := range ch.Admins {
+					msg := tg.NewMessage(int64(adm), errorText)
+					_, err := b.API.Send(msg)
 					if err != nil {
-						// continue
-						return errors.Wrapf(err, "Error getting chat information %s.", message.Chat.ID)
-					}
-					chatTitle := ch.Title
-					if ch.Type == "supergroup" && ch.UserName != "" {
-						chatTitle = "@" + ch.UserName
+						b.Log.Errorf("%+v", errors.Wrapf(err, "Error sending message to admin %d in chat %s.", adm, names.ChatName(message.Chat)))
 					}
-					adminText := fmt.Sprintf("Grant admin privileges to bot @%s in chat %s", b.API.Self.UserName, chatTitle)
-					for _, adm := range ch.Admins {
-						msg := tg.NewMessage(int64(adm), adminText)
-						_, err := b.API.Send(msg)
-						if err != nil {
-							return errors.Wrapf(err, "Error sending message to admin %d in chat %s.", adm, chatTitle)
-						}
-					}
-					return err1
-				}
-				// Формирование сообщения с кнопкой для перехода к тесту
-				messageText := fmt.Sprintf("Привет %s\nТы в режиме только для чтения. Для того, чтобы получить полные права в этом чате надо пройти тест.\nНажми кнопку под этим сообщением, чтобы пройти тест.", names.ShortUserName(&u))
-				msg := tg.NewMessage(message.Chat.ID, messageText)
-				msg.ParseMode = "Markdown"
-				msg.ReplyToMessageID = message.MessageID
-
-				buttons := tg.InlineKeyboardMarkup{
-					InlineKeyboard: [][]tg.InlineKeyboardButton{},
-				}
-				testButton := tg.NewInlineKeyboardButtonURL(
-					"Пройти тест",
-					fmt.Sprintf("tg://resolve?domain=%s&start=%d", b.API.Self.UserName, message.Chat.ID),
-				)
-				buttons.InlineKeyboard = append(buttons.InlineKeyboard, tg.NewInlineKeyboardRow(testButton))
-				msg.ReplyMarkup = buttons
-
-				// Отправить сообщение для подтверждения
-				res, err := b.API.Send(msg)
-				if err != nil {
-					// continue
-					return errors.Wrapf(err, "Error sending message to user %s.", names.FullUserName(message.From))
-				}
-				err = b.DB.UpdateConfirmReference(res.Chat.ID, res.MessageID, u.ID)
-				if err != nil {
-					// continue
-					return errors.Wrapf(err, "Error update reference to confirm message for user %s.", names.FullUserName(message.From))
 				}
 			}
-			// Add this chat to user's chats
-			err = b.DB.AddUnconfirmedChat(message.Chat.ID, u.ID)
-			if err != nil {
-				return errors.Wrapf(err, "Failed add chat `%s` to user's %s unconfirmed chats.", names.ChatName(message.Chat), names.ShortUserName(message.From))
-			}
 		}
+		if err == nil {
+			return nil
+		}
+	}
+
+	isConfirmed, err := b.DB.UserConfirmed(message.Chat.ID, u.ID)
+	if err != nil && err.Error() != mongo.ErrNoDocuments.Error() {
+		return errors.Wrap(err, "Failed check user confirmation in userAddedHandler.")
+	}
+	if isConfirmed && b.returningNeedsTest(message.Chat.ID, u.ID) {
+		b.Log.Infof("Returning user %s must pass the test again in chat %s", names.FullUserName(u), names.ChatName(message.Chat))
+		isConfirmed = false
+	}
+	if isConfirmed {
 		return nil
 	}
+
+	invited := b.chatSettings(message.Chat.ID).Invited
+	switch {
+	case inviterID != 0 && invited != config.InviteVerify && b.isTrustedInviter(message.Chat.ID, inviterID):
+		b.Log.Infof("User %s was added to chat %s by trusted member %s", names.FullUserName(u), names.ChatName(message.Chat), names.FullUserName(message.From))
+		return b.trustMember(message.Chat.ID, u, inviterID)
+	case b.federationTrusted(message.Chat.ID, u.ID):
+		// User passed the test in other chat of federation
+		b.Log.Infof("User %s was confirmed in chat %s by federation", names.FullUserName(u), names.ChatName(message.Chat))
+		return b.trustMember(message.Chat.ID, u, inviterID)
+	case inviterID != 0 && invited == config.InviteApprove:
+		return b.holdForApproval(message, u, inviterID)
+	case b.chatSettings(message.Chat.ID).Verification == config.VerifyApproval:
+		return b.holdForApproval(message, u, inviterID)
+	default:
+		return b.startVerification(message, u, inviterID)
+	}
+}
+
+// trustMember adds user to chat as confirmed without the test
+func (b *Bot) trustMember(chatID int64, u *tg.User, inviterID int) error {
+	err := b.DB.AddChatUser(chatID, config.ChatUser{
+		ID:        u.ID,
+		Confirmed: true,
+		InvitedBy: inviterID,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Failed add trusted user %s to chat %d.", names.FullUserName(u), chatID)
+	}
+	b.recordEvent(chatID, u.ID, config.MemberVerified, inviterID)
 	return nil
 }
 
+// startVerification restricts new user and sends welcome message with the test
+func (b *Bot) startVerification(message *tg.Message, u *tg.User, inviterID int) error {
+	nonce, err := newNonce()
+	if err != nil {
+		return errors.Wrap(err, "Failed generate nonce in userAddedHandler.")
+	}
+	// Remember restrictions made by admins to restore them after the test
+	restrictions, err := b.memberRestrictions(message.Chat.ID, u.ID)
+	if err != nil {
+		b.Log.Errorf("%+v", err)
+	}
+	err = b.DB.AddChatUser(message.Chat.ID, config.ChatUser{
+		ID:           u.ID,
+		Confirmed:    false,
+		MsgCount:     0,
+		Nonce:        nonce,
+		Restrictions: restrictions,
+		InvitedBy:    inviterID,
+	})
+	if err != nil {
+		return errors.Wrap(err, "Failed add user to chat.")
+	}
+	err = b.restrictNewMember(message.Chat.ID, u.ID)
+	if err != nil {
+		return err
+	}
+	return b.sendWelcome(message.Chat.ID, u, message.MessageID, nonce)
+}
+
+// sendWelcome sends welcome message with the test to restricted user and schedules kick
+// if the test will not be passed in time
+func (b *Bot) sendWelcome(chatID int64, u *tg.User, replyTo int, nonce string) error {
+	// Формирование сообщения с кнопкой для перехода к тесту
+	msg := b.TGMessageWelcome(chatID, u, replyTo, nonce)
+
+	// Отправить сообщение для подтверждения
+	res, err := b.API.Send(msg)
+	if err != nil {
+		return errors.Wrapf(err, "Error sending message to user %s.", names.FullUserName(u))
+	}
+	err = b.DB.UpdateConfirmReference(res.Chat.ID, res.MessageID, u.ID)
+	if err != nil {
+		return errors.Wrapf(err, "Error update reference to confirm message for user %s.", names.FullUserName(u))
+	}
+	// Kick user if test will not be passed in time
+	timeout := time.Duration(b.chatSettings(chatID).ConfirmTimeout) * time.Second
+	err = b.schedule(jobKickUnconfirmed, chatID, u.ID, timeout)
+	if err != nil {
+		return errors.Wrapf(err, "Error schedule kick of unconfirmed user %s.", names.FullUserName(u))
+	}
+	// Add this chat to user's chats
+	err = b.DB.AddUnconfirmedChat(chatID, u.ID)
+	if err != nil {
+		return errors.Wrapf(err, "Failed add chat %d to user's %s unconfirmed chats.", chatID, names.ShortUserName(u))
+	}
+	return nil
+}
+
+// restrictNewMember forbids new user to send anything to chat
+func (b *Bot) restrictNewMember(chatID int64, userID int) error {
+	var f bool = false
+	// Restrict user permissions
+	resp, err := b.API.RestrictChatMember(tg.RestrictChatMemberConfig{
+		ChatMemberConfig: tg.ChatMemberConfig{
+			ChatID: chatID,
+			UserID: userID,
+		},
+		CanSendMessages:       &f,
+		CanSendMediaMessages:  &f,
+		CanSendOtherMessages:  &f,
+		CanAddWebPagePreviews: &f,
+	})
+	if err == nil {
+		return nil
+	}
+	err1 := errors.Wrapf(err, "Failed restrict new user privileges with code %d and error %s", resp.ErrorCode, resp.Description)
+
+	// Send message to admins that bot needs to be granted admin privileges
+	ch, err := b.DB.GetChatInfo(chatID)
+	if err != nil {
+		b.Log.Error(err1)
+		return errors.Wrapf(err, "Error getting chat information %d.", chatID)
+	}
+	chatTitle := ch.Title
+	if ch.Type == "supergroup" && ch.UserName != "" {
+		chatTitle = "@" + ch.UserName
+	}
+	adminText := fmt.Sprintf("Grant admin privileges to bot @%s in chat %s", b.API.Self.UserName, chatTitle)
+	for _, adm := range ch.Admins {
+		msg := tg.NewMessage(int64(adm), adminText)
+		_, err := b.API.Send(msg)
+		if err != nil {
+			b.Log.Errorf("%+v", errors.Wrapf(err, "Error sending message to admin %d in chat %s.", adm, chatTitle))
+		}
+	}
+	return err1
+}
+
 func (b *Bot) userLeftHandler(message *tg.Message) error {
+	if message.LeftChatMember.ID == b.API.Self.ID {
+		return nil
+	}
+	if message.From.ID == message.LeftChatMember.ID {
+		b.recordEvent(message.Chat.ID, message.LeftChatMember.ID, config.MemberLeft, 0)
+	} else {
+		b.recordEvent(message.Chat.ID, message.LeftChatMember.ID, config.MemberKicked, message.From.ID)
+	}
+
 	// Remove from users list if user was not confirmed
 	ref, err := b.DB.RemoveUnconfirmedChatUser(message.Chat.ID, message.LeftChatMember.ID)
 	if err != nil {
@@ -276,6 +427,16 @@ func (b *Bot) userLeftHandler(message *tg.Message) error {
 			b.Log.Errorf("Error delete confirmation message from chat %s %v", names.ChatName(message.Chat), err.Error())
 		}
 	}
+	// Delete chat from user's unconfirmed chats
+	err = b.DB.DeleteUnconfirmedChat(message.Chat.ID, message.LeftChatMember.ID)
+	if err != nil {
+		b.Log.Errorf("%+v", err)
+	}
+	// Cancel kick of unconfirmed user
+	err = b.unschedule(jobKickUnconfirmed, message.Chat.ID, message.LeftChatMember.ID)
+	if err != nil {
+		b.Log.Errorf("%+v", err)
+	}
 	// Remove from admins list
 	err = b.DB.RemoveChatAdmin(message.Chat.ID, message.LeftChatMember.ID)
 	if err != nil {
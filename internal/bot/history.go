@@ -0,0 +1,94 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"tg-group-control-bot/internal/config"
+	"tg-group-control-bot/internal/names"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/pkg/errors"
+)
+
+// Number of events shown by /history
+const historyLimit = 20
+
+var memberEventNames = map[string]string{
+	config.MemberJoined:   "вступил",
+	config.MemberVerified: "прошёл проверку",
+	config.MemberLeft:     "вышел",
+	config.MemberKicked:   "удалён",
+}
+
+// recordEvent saves change of membership to history
+func (b *Bot) recordEvent(chatID int64, userID int, kind string, actorID int) {
+	err := b.DB.AddMemberEvent(config.MemberEvent{
+		ChatID:  chatID,
+		UserID:  userID,
+		Kind:    kind,
+		Date:    time.Now().Unix(),
+		ActorID: actorID,
+	})
+	if err != nil {
+		b.Log.Errorf("%+v", errors.Wrapf(err, "Failed save event %s of user %d in chat %d", kind, userID, chatID))
+	}
+}
+
+// returningNeedsTest checks that confirmed user which joined chat again must pass the test
+func (b *Bot) returningNeedsTest(chatID int64, userID int) bool {
+	settings := b.chatSettings(chatID)
+	switch settings.Returning {
+	case config.ReturnVerify:
+		return true
+	case config.ReturnAbsent:
+		e, err := b.DB.LastMemberEvent(chatID, userID, config.MemberLeft, config.MemberKicked)
+		if err != nil {
+			// Leaving was not noticed, so absence is unknown
+			return false
+		}
+		absence := time.Now().Unix() - e.Date
+		return absence > int64(settings.ReturnDays)*24*60*60
+	default:
+		return false
+	}
+}
+
+// historyCommand handles /history <userID> sent by admin to group chat.
+// Replying to message of user with /history shows history of author of message.
+func (b *Bot) historyCommand(message *tg.Message) error {
+	if !b.groupAdminAllowed(message) {
+		return nil
+	}
+
+	var userID int
+	if message.ReplyToMessage != nil && message.ReplyToMessage.From != nil {
+		userID = message.ReplyToMessage.From.ID
+	} else {
+		id, err := strconv.Atoi(strings.TrimSpace(message.CommandArguments()))
+		if err != nil {
+			return b.replyPrivate(message, "Используйте: /history <ID пользователя> или ответьте командой на сообщение пользователя")
+		}
+		userID = id
+	}
+
+	events, err := b.DB.GetMemberHistory(message.Chat.ID, userID, historyLimit)
+	if err != nil {
+		return errors.Wrapf(err, "Failed get history of user %d in chat %s", userID, names.ChatName(message.Chat))
+	}
+	if len(events) == 0 {
+		return b.replyPrivate(message, fmt.Sprintf("В чате %s нет истории пользователя %d", message.Chat.Title, userID))
+	}
+
+	text := []string{fmt.Sprintf("История пользователя %d в чате %s:", userID, message.Chat.Title)}
+	for _, e := range events {
+		line := fmt.Sprintf("%s %s", time.Unix(e.Date, 0).Format("2006-01-02 15:04"), memberEventNames[e.Kind])
+		if e.ActorID != 0 {
+			line += fmt.Sprintf(" (пользователем %d)", e.ActorID)
+		}
+		text = append(text, line)
+	}
+	return b.replyPrivate(message, strings.Join(text, "\n"))
+}
@@ -0,0 +1,66 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"tg-group-control-bot/internal/config"
+	"tg-group-control-bot/internal/names"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/pkg/errors"
+)
+
+// isTrustedInviter checks that users added by member may skip the test.
+// Admin with any rights is trusted, like admin adding bots.
+func (b *Bot) isTrustedInviter(chatID int64, userID int) bool {
+	if b.isChatAdminWith(chatID, userID, anyAdminRights) {
+		return true
+	}
+	ch, err := b.DB.GetChatInfo(chatID)
+	if err != nil {
+		return false
+	}
+	for _, id := range ch.Trusted {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// trustCommand handles /trust <userID> and /untrust <userID> sent by admin to group chat.
+// Replying to message of user with the command changes trust of author of message.
+func (b *Bot) trustCommand(message *tg.Message, trusted bool) error {
+	if !b.groupAdminAllowed(message) {
+		return nil
+	}
+
+	var userID int
+	if message.ReplyToMessage != nil && message.ReplyToMessage.From != nil {
+		userID = message.ReplyToMessage.From.ID
+	} else {
+		id, err := strconv.Atoi(strings.TrimSpace(message.CommandArguments()))
+		if err != nil {
+			return b.replyGroup(message, fmt.Sprintf("Используйте: /%s <ID пользователя> или ответьте командой на сообщение пользователя", message.Command()))
+		}
+		userID = id
+	}
+
+	err := b.DB.SetChatTrusted(message.Chat.ID, userID, trusted)
+	if err != nil {
+		return errors.Wrapf(err, "Failed change trust of user %d in chat %s", userID, names.ChatName(message.Chat))
+	}
+	if !trusted {
+		return b.replyGroup(message, fmt.Sprintf("Участник %d больше не доверенный", userID))
+	}
+	switch b.chatSettings(message.Chat.ID).Invited {
+	case config.InviteTrust:
+		return b.replyGroup(message, fmt.Sprintf("Пользователи, добавленные участником %d, не проходят проверку", userID))
+	case config.InviteApprove:
+		return b.replyGroup(message, fmt.Sprintf("Пользователи, добавленные участником %d, не ждут одобрения и не проходят проверку", userID))
+	default:
+		return b.replyGroup(message, fmt.Sprintf("Участник %d доверенный, но сейчас все добавленные пользователи проходят проверку. Режим приглашённых меняется в /settings", userID))
+	}
+}
@@ -0,0 +1,147 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"tg-group-control-bot/internal/challenge"
+	"tg-group-control-bot/internal/config"
+	"tg-group-control-bot/internal/names"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/pkg/errors"
+)
+
+// Joins are counted during this window to detect raid
+const raidWindow = time.Minute
+
+// Challenge and attempts given to users which pass the test during lockdown
+const (
+	lockdownChallenge   = "captcha"
+	lockdownMaxAttempts = 1
+)
+
+// registerJoin counts join to chat, starts lockdown if too many users joined and
+// prolongs lockdown while users join. Returns true if chat is in lockdown.
+func (b *Bot) registerJoin(chatID int64) bool {
+	settings := b.chatSettings(chatID)
+	if settings.RaidThreshold < 0 {
+		return b.inLockdown(chatID)
+	}
+
+	joins := b.Raid.Join(chatID, time.Now(), raidWindow)
+	locked := b.inLockdown(chatID)
+	if !locked && joins >= settings.RaidThreshold {
+		started, err := b.DB.SetChatLockdown(chatID, true)
+		if err != nil {
+			b.Log.Errorf("%+v", errors.Wrapf(err, "Failed start lockdown of chat %d", chatID))
+			return false
+		}
+		locked = true
+		// Only the first of concurrent joins notifies admins
+		if started {
+			b.Log.Warnf("Lockdown of chat %d started after %d joins in %s", chatID, joins, raidWindow)
+			b.notifyAdmins(chatID, fmt.Sprintf("В чат %s вступили %d пользователей за минуту. Включён режим блокировки: %s. Режим снимется после %s без новых вступлений, снять вручную: /lockdown off",
+				b.DB.GetChatTitle(chatID), joins, raidActionText(settings.RaidAction), formatDuration(settings.RaidQuiet)))
+		}
+	}
+	if !locked {
+		return false
+	}
+
+	// Lockdown is lifted after quiet period, every join prolongs it
+	err := b.schedule(jobLiftLockdown, chatID, 0, time.Duration(settings.RaidQuiet)*time.Second)
+	if err != nil {
+		b.Log.Errorf("%+v", errors.Wrapf(err, "Failed schedule lift of lockdown in chat %d", chatID))
+	}
+	return true
+}
+
+// inLockdown checks that chat is under raid
+func (b *Bot) inLockdown(chatID int64) bool {
+	ch, err := b.DB.GetChatInfo(chatID)
+	if err != nil {
+		return false
+	}
+	return ch.Lockdown
+}
+
+// lockdownMember handles user joined during lockdown
+func (b *Bot) lockdownMember(message *tg.Message, u *tg.User, inviterID int) error {
+	settings := b.chatSettings(message.Chat.ID)
+	if settings.RaidAction == config.RaidApproval {
+		return b.holdForApproval(message, u, inviterID)
+	}
+
+	// User can join again after lockdown is lifted
+	err := b.kickPending(message.Chat.ID, u.ID, time.Now().Unix()+settings.RaidQuiet)
+	if err != nil {
+		return err
+	}
+	b.Log.Infof("User %s was kicked from chat %s during lockdown", names.FullUserName(u), names.ChatName(message.Chat))
+	return nil
+}
+
+// liftLockdown turns lockdown off after quiet period
+func (b *Bot) liftLockdown(job config.Job) error {
+	lifted, err := b.DB.SetChatLockdown(job.ChatID, false)
+	if err != nil {
+		return errors.Wrapf(err, "Failed lift lockdown of chat %d", job.ChatID)
+	}
+	if lifted {
+		b.Log.Infof("Lockdown of chat %d was lifted", job.ChatID)
+		b.notifyAdmins(job.ChatID, fmt.Sprintf("Режим блокировки в чате %s снят", b.DB.GetChatTitle(job.ChatID)))
+	}
+	return nil
+}
+
+// lockdownChatChallenge returns harder challenge for chat in lockdown
+func (b *Bot) lockdownChatChallenge(chatID int64) (challenge.Challenge, bool) {
+	if !b.inLockdown(chatID) {
+		return nil, false
+	}
+	return challenge.Get(lockdownChallenge), true
+}
+
+// lockdownCommand handles /lockdown on|off sent by admin to group chat
+func (b *Bot) lockdownCommand(message *tg.Message) error {
+	if !b.groupAdminAllowed(message) {
+		return nil
+	}
+
+	switch strings.TrimSpace(message.CommandArguments()) {
+	case "on":
+		settings := b.chatSettings(message.Chat.ID)
+		_, err := b.DB.SetChatLockdown(message.Chat.ID, true)
+		if err != nil {
+			return errors.Wrapf(err, "Failed start lockdown of chat %s", names.ChatName(message.Chat))
+		}
+		err = b.schedule(jobLiftLockdown, message.Chat.ID, 0, time.Duration(settings.RaidQuiet)*time.Second)
+		if err != nil {
+			b.Log.Errorf("%+v", err)
+		}
+		b.Log.Infof("Lockdown of chat %s was started by %s", names.ChatName(message.Chat), names.ShortUserName(message.From))
+		return b.replyGroup(message, fmt.Sprintf("Режим блокировки включён: %s", raidActionText(settings.RaidAction)))
+	case "off":
+		_, err := b.DB.SetChatLockdown(message.Chat.ID, false)
+		if err != nil {
+			return errors.Wrapf(err, "Failed lift lockdown of chat %s", names.ChatName(message.Chat))
+		}
+		err = b.unschedule(jobLiftLockdown, message.Chat.ID, 0)
+		if err != nil {
+			b.Log.Errorf("%+v", err)
+		}
+		b.Log.Infof("Lockdown of chat %s was lifted by %s", names.ChatName(message.Chat), names.ShortUserName(message.From))
+		return b.replyGroup(message, "Режим блокировки снят")
+	default:
+		return b.replyGroup(message, "Используйте: /lockdown on|off")
+	}
+}
+
+func raidActionText(action string) string {
+	if action == config.RaidApproval {
+		return "новые участники ждут одобрения администратора"
+	}
+	return "новые участники удаляются"
+}
@@ -4,38 +4,63 @@ import (
 	"fmt"
 	"strings"
 
+	"tg-group-control-bot/internal/challenge"
+	"tg-group-control-bot/internal/config"
+	"tg-group-control-bot/internal/names"
+
 	tg "github.com/go-telegram-bot-api/telegram-bot-api"
 )
 
-func (b *Bot) prepareText(chat string, isInvalid bool) string {
+func (b *Bot) prepareText(chat string, task challenge.Task, isInvalid bool) string {
 	invalid := "Неверный ответ. Попробуйте снова.\n\n"
 	description := "Для получения доступа к чату " + chat + " ответьте на вопрос.\n\n"
-	question := "Вы бот?"
 	text := make([]string, 0)
 
 	if isInvalid {
 		text = append(text, invalid)
 	}
-	text = append(text, description, question)
+	text = append(text, description, task.Question)
+	if task.Hint != "" {
+		text = append(text, "\n\n", task.Hint)
+	}
 	return strings.Join(text, "")
 }
 
 // TGMessageQuestion returns telegram message with question for confirm
-func (b *Bot) TGMessageQuestion(fromChatID, toChatID int64) *tg.MessageConfig {
-	text := b.prepareText(b.DB.GetChatTitle(fromChatID), false)
+func (b *Bot) TGMessageQuestion(fromChatID, toChatID int64, task challenge.Task) *tg.MessageConfig {
+	text := b.prepareText(b.DB.GetChatTitle(fromChatID), task, false)
 	msg := tg.NewMessage(toChatID, text)
+	if len(task.Options) > 0 {
+		msg.ReplyMarkup = optionsKeyboard(fromChatID, task)
+	}
 
 	return &msg
 }
 
 // TGMessageInvalid returns telegram message with text about incorrect answer
-func (b *Bot) TGMessageInvalid(fromChatID, toChatID int64) *tg.MessageConfig {
-	text := b.prepareText(b.DB.GetChatTitle(fromChatID), true)
+func (b *Bot) TGMessageInvalid(fromChatID, toChatID int64, task challenge.Task) *tg.MessageConfig {
+	text := b.prepareText(b.DB.GetChatTitle(fromChatID), task, true)
 	msg := tg.NewMessage(toChatID, text)
+	if len(task.Options) > 0 {
+		msg.ReplyMarkup = optionsKeyboard(fromChatID, task)
+	}
 
 	return &msg
 }
 
+// optionsKeyboard returns buttons with answer variants of task, three in row
+func optionsKeyboard(chatID int64, task challenge.Task) tg.InlineKeyboardMarkup {
+	rows := make([][]tg.InlineKeyboardButton, 0)
+	for i, option := range task.Options {
+		if i%3 == 0 {
+			rows = append(rows, tg.NewInlineKeyboardRow())
+		}
+		button := tg.NewInlineKeyboardButtonData(option, callbackData(answerPrefix, chatID, i))
+		rows[len(rows)-1] = append(rows[len(rows)-1], button)
+	}
+	return tg.NewInlineKeyboardMarkup(rows...)
+}
+
 // TGMessageSuccess returns telegram message with success text
 func (b *Bot) TGMessageSuccess(fromChatID, toChatID int64) *tg.MessageConfig {
 	text := fmt.Sprintf("Вы прошли тест!\nВы получили доступ к чату %s", b.DB.GetChatTitle(fromChatID))
@@ -43,3 +68,40 @@ func (b *Bot) TGMessageSuccess(fromChatID, toChatID int64) *tg.MessageConfig {
 
 	return &msg
 }
+
+// TGMessageWelcome returns message for group chat with button to pass the test
+func (b *Bot) TGMessageWelcome(chatID int64, user *tg.User, replyTo int, nonce string) *tg.MessageConfig {
+	settings := b.chatSettings(chatID)
+	text := strings.ReplaceAll(settings.Welcome, "{user}", names.ShortUserName(user))
+	msg := tg.NewMessage(chatID, text)
+	msg.ParseMode = "Markdown"
+	msg.ReplyToMessageID = replyTo
+
+	buttons := tg.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tg.InlineKeyboardButton{},
+	}
+	testButton := tg.NewInlineKeyboardButtonURL(
+		"Пройти тест",
+		b.testLink(chatID, user.ID, settings.ConfirmTimeout),
+	)
+	// Chat in lockdown requires captcha in private chat instead of the button
+	if settings.Verification == config.VerifyButton && !b.inLockdown(chatID) {
+		testButton = tg.NewInlineKeyboardButtonData("Я не бот", callbackData(verifyPrefix, user.ID, nonce))
+	}
+	buttons.InlineKeyboard = append(buttons.InlineKeyboard, tg.NewInlineKeyboardRow(testButton))
+	msg.ReplyMarkup = buttons
+
+	return &msg
+}
+
+// formatDuration returns human readable duration passed in seconds
+func formatDuration(seconds int64) string {
+	switch {
+	case seconds >= 24*3600 && seconds%(24*3600) == 0:
+		return fmt.Sprintf("%d дн", seconds/(24*3600))
+	case seconds >= 3600 && seconds%3600 == 0:
+		return fmt.Sprintf("%d ч", seconds/3600)
+	default:
+		return fmt.Sprintf("%d мин", seconds/60)
+	}
+}
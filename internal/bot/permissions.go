@@ -0,0 +1,59 @@
+package bot
+
+import (
+	"tg-group-control-bot/internal/config"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/pkg/errors"
+)
+
+// allPermissions lifts individual restrictions of member, default permissions of chat still apply
+var allPermissions = config.Permissions{
+	CanSendMessages:       true,
+	CanSendMediaMessages:  true,
+	CanSendOtherMessages:  true,
+	CanAddWebPagePreviews: true,
+}
+
+// memberRestrictions returns individual restrictions of user or nil if user is not restricted.
+// Must be called before new record of user is added to chat.
+func (b *Bot) memberRestrictions(chatID int64, userID int) (*config.Permissions, error) {
+	// User is still pending or on probation from previous join, so current restrictions were made by bot.
+	// Restrictions saved on that join are the restrictions of admins.
+	if cu, err := b.DB.GetChatUser(chatID, userID); err == nil && (!cu.Confirmed || cu.Probation) {
+		return cu.Restrictions, nil
+	}
+
+	m, err := b.API.GetChatMember(tg.ChatConfigWithUser{
+		ChatID: chatID,
+		UserID: userID,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed get member %d of chat %d", userID, chatID)
+	}
+	if m.Status != "restricted" {
+		return nil, nil
+	}
+
+	p := config.Permissions{
+		CanSendMessages:       m.CanSendMessages,
+		CanSendMediaMessages:  m.CanSendMediaMessages,
+		CanSendOtherMessages:  m.CanSendOtherMessages,
+		CanAddWebPagePreviews: m.CanAddWebPagePreviews,
+	}
+	return &p, nil
+}
+
+// confirmedPermissions returns permissions of user after passing the test.
+// Only restrictions made by admins are kept, so user follows later changes of chat defaults.
+func (b *Bot) confirmedPermissions(chatID int64, userID int) config.Permissions {
+	p := allPermissions
+	cu, err := b.DB.GetChatUser(chatID, userID)
+	if err != nil {
+		b.Log.Errorf("%+v", errors.Wrapf(err, "Failed get restrictions of user %d in chat %d", userID, chatID))
+	}
+	if cu.Restrictions != nil {
+		p = p.Intersect(*cu.Restrictions)
+	}
+	return p
+}
@@ -0,0 +1,159 @@
+package bot
+
+import (
+	"time"
+
+	"tg-group-control-bot/internal/config"
+	"tg-group-control-bot/internal/names"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/pkg/errors"
+)
+
+// Verified user can send only text until probation ends.
+// Links and forwarded messages of user on probation are deleted.
+var probationPermissions = config.Permissions{CanSendMessages: true}
+
+// startProbation limits verified user and schedules end of probation
+func (b *Bot) startProbation(chatID int64, userID int, settings config.ChatSettings) error {
+	_, err := b.DB.SetChatUserProbation(chatID, userID, true)
+	if err != nil {
+		return errors.Wrapf(err, "Failed start probation of user %d in chat %d", userID, chatID)
+	}
+	err = b.schedule(jobEndProbation, chatID, userID, time.Duration(settings.ProbationTime)*time.Second)
+	if err != nil {
+		return errors.Wrapf(err, "Failed schedule end of probation of user %d in chat %d", userID, chatID)
+	}
+	return nil
+}
+
+// endProbation grants user all permissions which are allowed in chat.
+// Probation stays in storage if permissions were not granted, so it can be ended later.
+func (b *Bot) endProbation(chatID int64, userID int) error {
+	cu, err := b.DB.GetChatUser(chatID, userID)
+	if err != nil {
+		return errors.Wrapf(err, "Failed get user %d of chat %d", userID, chatID)
+	}
+	if !cu.Probation {
+		return nil
+	}
+
+	p, err := b.afterProbationPermissions(chatID, userID)
+	if err != nil {
+		return err
+	}
+	resp, err := b.API.RestrictChatMember(tg.RestrictChatMemberConfig{
+		ChatMemberConfig: tg.ChatMemberConfig{
+			ChatID: chatID,
+			UserID: userID,
+		},
+		CanSendMessages:       &p.CanSendMessages,
+		CanSendMediaMessages:  &p.CanSendMediaMessages,
+		CanSendOtherMessages:  &p.CanSendOtherMessages,
+		CanAddWebPagePreviews: &p.CanAddWebPagePreviews,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Failed restore privileges of user %d after probation with code %d and error %s", userID, resp.ErrorCode, resp.Description)
+	}
+	ended, err := b.DB.SetChatUserProbation(chatID, userID, false)
+	if err != nil {
+		return errors.Wrapf(err, "Failed end probation of user %d in chat %d", userID, chatID)
+	}
+	if !ended {
+		// Probation was ended concurrently
+		return nil
+	}
+	err = b.unschedule(jobEndProbation, chatID, userID)
+	if err != nil {
+		b.Log.Errorf("%+v", err)
+	}
+	b.Log.Infof("Probation of user %d in chat %d has ended", userID, chatID)
+	return nil
+}
+
+// afterProbationPermissions returns permissions of user after probation.
+// Restrictions changed by admins during probation, like mute, are kept.
+func (b *Bot) afterProbationPermissions(chatID int64, userID int) (config.Permissions, error) {
+	p := b.confirmedPermissions(chatID, userID)
+	m, err := b.API.GetChatMember(tg.ChatConfigWithUser{
+		ChatID: chatID,
+		UserID: userID,
+	})
+	if err != nil {
+		return p, errors.Wrapf(err, "Failed get member %d of chat %d", userID, chatID)
+	}
+	if m.Status != "restricted" {
+		return p, nil
+	}
+
+	current := config.Permissions{
+		CanSendMessages:       m.CanSendMessages,
+		CanSendMediaMessages:  m.CanSendMediaMessages,
+		CanSendOtherMessages:  m.CanSendOtherMessages,
+		CanAddWebPagePreviews: m.CanAddWebPagePreviews,
+	}
+	// Bot restricted user to these permissions on verification
+	if current == p.Intersect(probationPermissions) {
+		return p, nil
+	}
+	return p.Intersect(current), nil
+}
+
+// endProbationJob ends probation when its time has passed
+func (b *Bot) endProbationJob(job config.Job) error {
+	return b.endProbation(job.ChatID, job.UserID)
+}
+
+// countMessage counts message of user in group chat and ends probation after enough messages
+func (b *Bot) countMessage(message *tg.Message) error {
+	if probationForbidden(message) {
+		cu, err := b.DB.GetChatUser(message.Chat.ID, message.From.ID)
+		if err != nil || !cu.Probation {
+			return nil
+		}
+		_, err = b.API.DeleteMessage(tg.DeleteMessageConfig{
+			ChatID:    message.Chat.ID,
+			MessageID: message.MessageID,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "Error delete message of user %s on probation in chat %s", names.ShortUserName(message.From), names.ChatName(message.Chat))
+		}
+		b.Log.Infof("Message of user %s on probation was deleted from chat %s", names.ShortUserName(message.From), names.ChatName(message.Chat))
+		return nil
+	}
+
+	cu, err := b.DB.IncChatUserMessages(message.Chat.ID, message.From.ID)
+	if err != nil {
+		// Members joined before bot are not known
+		return nil
+	}
+	if !cu.Probation {
+		return nil
+	}
+	settings := b.chatSettings(message.Chat.ID)
+	if cu.MsgCount >= uint64(settings.ProbationMessages) {
+		return b.endProbation(message.Chat.ID, message.From.ID)
+	}
+	// Job ending probation in time may have failed
+	e, err := b.DB.LastMemberEvent(message.Chat.ID, message.From.ID, config.MemberVerified)
+	if err == nil && time.Now().Unix()-e.Date >= settings.ProbationTime {
+		return b.endProbation(message.Chat.ID, message.From.ID)
+	}
+	return nil
+}
+
+// probationForbidden checks that message contains forward or link
+func probationForbidden(message *tg.Message) bool {
+	if message.ForwardFrom != nil || message.ForwardFromChat != nil || message.ForwardDate != 0 {
+		return true
+	}
+	if message.Entities == nil {
+		return false
+	}
+	for _, e := range *message.Entities {
+		if e.Type == "url" || e.Type == "text_link" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"tg-group-control-bot/internal/challenge"
+	"tg-group-control-bot/internal/names"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/pkg/errors"
+)
+
+// Commands of question bank are sent to group chat by admin. Bot deletes command
+// from group chat, so that answers are not visible to members, and replies in private chat.
+
+// addQuestionCommand handles /addquestion Question | answer | answer
+func (b *Bot) addQuestionCommand(message *tg.Message) error {
+	if !b.questionBankAllowed(message) {
+		return nil
+	}
+
+	parts := strings.Split(message.CommandArguments(), "|")
+	item := challenge.Item{Text: strings.TrimSpace(parts[0])}
+	for _, answer := range parts[1:] {
+		if answer = strings.TrimSpace(answer); answer != "" {
+			item.Answers = append(item.Answers, answer)
+		}
+	}
+	if item.Text == "" || len(item.Answers) == 0 {
+		return b.replyPrivate(message, "Используйте: /addquestion Вопрос | ответ | другой ответ")
+	}
+
+	err := b.DB.AddChatQuestion(message.Chat.ID, item)
+	if err != nil {
+		return errors.Wrapf(err, "Error add question to chat %s", names.ChatName(message.Chat))
+	}
+	return b.replyPrivate(message, fmt.Sprintf("Вопрос добавлен в чат %s:\n%s", message.Chat.Title, formatQuestion(item)))
+}
+
+// questionsCommand handles /questions
+func (b *Bot) questionsCommand(message *tg.Message) error {
+	if !b.questionBankAllowed(message) {
+		return nil
+	}
+
+	questions, err := b.DB.GetChatQuestions(message.Chat.ID)
+	if err != nil {
+		return errors.Wrapf(err, "Error getting questions of chat %s", names.ChatName(message.Chat))
+	}
+	if len(questions) == 0 {
+		return b.replyPrivate(message, fmt.Sprintf("В чате %s нет своих вопросов, используется стандартный вопрос", message.Chat.Title))
+	}
+
+	text := []string{fmt.Sprintf("Вопросы чата %s:", message.Chat.Title)}
+	for i, q := range questions {
+		text = append(text, fmt.Sprintf("%d. %s", i+1, formatQuestion(q)))
+	}
+	return b.replyPrivate(message, strings.Join(text, "\n\n"))
+}
+
+// delQuestionCommand handles /delquestion <number>
+func (b *Bot) delQuestionCommand(message *tg.Message) error {
+	if !b.questionBankAllowed(message) {
+		return nil
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(message.CommandArguments()))
+	if err != nil {
+		return b.replyPrivate(message, "Используйте: /delquestion <номер вопроса из /questions>")
+	}
+	q, err := b.DB.RemoveChatQuestion(message.Chat.ID, n-1)
+	if err != nil {
+		b.Log.Errorf("%+v", errors.Wrapf(err, "Error remove question from chat %s", names.ChatName(message.Chat)))
+		return b.replyPrivate(message, fmt.Sprintf("Не удалось удалить вопрос %d", n))
+	}
+	return b.replyPrivate(message, fmt.Sprintf("Вопрос удалён из чата %s:\n%s", message.Chat.Title, formatQuestion(q)))
+}
+
+// questionBankAllowed checks that command was sent by admin to group chat and deletes it
+func (b *Bot) questionBankAllowed(message *tg.Message) bool {
+	if message.Chat.IsPrivate() {
+		_, err := b.API.Send(tg.NewMessage(message.Chat.ID, "Отправьте эту команду в группу, вопросы которой нужно изменить"))
+		if err != nil {
+			b.Log.Errorf("%+v", errors.Wrapf(err, "Error sending message to %d.", message.Chat.ID))
+		}
+		return false
+	}
+
+	// Hide question and answers from chat members
+	_, err := b.API.DeleteMessage(tg.DeleteMessageConfig{
+		ChatID:    message.Chat.ID,
+		MessageID: message.MessageID,
+	})
+	if err != nil {
+		b.Log.Errorf("Error delete command from chat %s %v", names.ChatName(message.Chat), err.Error())
+	}
+
+	if !b.isChatAdmin(message.Chat.ID, message.From.ID) {
+		b.Log.Warnf("User %s is not admin of chat %s and cannot use command %s", names.ShortUserName(message.From), names.ChatName(message.Chat), message.Command())
+		return false
+	}
+	return true
+}
+
+// replyPrivate sends text to private chat with sender of message
+func (b *Bot) replyPrivate(message *tg.Message, text string) error {
+	_, err := b.API.Send(tg.NewMessage(int64(message.From.ID), text))
+	if err != nil {
+		return errors.Wrapf(err, "Error sending private message to user %s.", names.ShortUserName(message.From))
+	}
+	return nil
+}
+
+func formatQuestion(q challenge.Item) string {
+	return fmt.Sprintf("%s\nОтветы: %s", q.Text, strings.Join(q.Answers, ", "))
+}
@@ -0,0 +1,100 @@
+package bot
+
+import (
+	"time"
+
+	"tg-group-control-bot/internal/config"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Kick user which did not pass the test in time
+	jobKickUnconfirmed = "kick-unconfirmed"
+	// Lift lockdown of chat after quiet period
+	jobLiftLockdown = "lift-lockdown"
+	// Grant verified user all permissions after probation
+	jobEndProbation = "end-probation"
+
+	schedulerInterval = time.Minute
+)
+
+// schedule saves job which will be executed after passed duration
+func (b *Bot) schedule(kind string, chatID int64, userID int, after time.Duration) error {
+	return b.DB.AddJob(config.Job{
+		Kind:     kind,
+		ChatID:   chatID,
+		UserID:   userID,
+		Deadline: time.Now().Add(after).Unix(),
+	})
+}
+
+// unschedule cancels job
+func (b *Bot) unschedule(kind string, chatID int64, userID int) error {
+	return b.DB.RemoveJob(kind, chatID, userID)
+}
+
+// runScheduler periodically executes jobs which deadline has passed
+func (b *Bot) runScheduler() {
+	// Jobs may have expired while bot was stopped
+	b.runDueJobs()
+
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		b.runDueJobs()
+	}
+}
+
+func (b *Bot) runDueJobs() {
+	jobs, err := b.DB.DueJobs(time.Now().Unix())
+	if err != nil {
+		b.Log.Errorf("%+v", errors.Wrap(err, "Failed get due jobs"))
+		return
+	}
+
+	for _, job := range jobs {
+		handler := b.jobHandler(job.Kind)
+		if handler == nil {
+			b.Log.Errorf("Unknown job kind %s for user %d in chat %d", job.Kind, job.UserID, job.ChatID)
+		} else if err := handler(job); err != nil {
+			b.Log.Errorf("%+v", errors.Wrapf(err, "Failed execute job %s for user %d in chat %d", job.Kind, job.UserID, job.ChatID))
+		}
+		// Job is removed even if it was failed, otherwise it will be repeated forever
+		if err := b.unschedule(job.Kind, job.ChatID, job.UserID); err != nil {
+			b.Log.Errorf("%+v", err)
+		}
+	}
+}
+
+func (b *Bot) jobHandler(kind string) func(config.Job) error {
+	switch kind {
+	case jobKickUnconfirmed:
+		return b.kickUnconfirmed
+	case jobLiftLockdown:
+		return b.liftLockdown
+	case jobEndProbation:
+		return b.endProbationJob
+	default:
+		return nil
+	}
+}
+
+// kickUnconfirmed removes user from chat if user did not pass the test
+func (b *Bot) kickUnconfirmed(job config.Job) error {
+	isConfirmed, err := b.DB.UserConfirmed(job.ChatID, job.UserID)
+	if err != nil {
+		return errors.Wrap(err, "Failed check user confirmation in kickUnconfirmed.")
+	}
+	if isConfirmed {
+		return nil
+	}
+
+	err = b.kickPending(job.ChatID, job.UserID, 0)
+	if err != nil {
+		return err
+	}
+
+	b.Log.Infof("Unconfirmed user %d was kicked from chat %d", job.UserID, job.ChatID)
+	return nil
+}
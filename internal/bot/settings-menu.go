@@ -0,0 +1,356 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"tg-group-control-bot/internal/challenge"
+	"tg-group-control-bot/internal/config"
+	"tg-group-control-bot/internal/names"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/pkg/errors"
+)
+
+const settingsPrefix = "settings"
+
+// Values of test timeout which admin can choose
+var timeoutPresets = []int64{3600, 3 * 3600, 6 * 3600, 12 * 3600, 24 * 3600, 48 * 3600}
+
+var verificationModes = []string{config.VerifyPrivate, config.VerifyButton, config.VerifyApproval}
+
+var sweepActions = []string{config.SweepReport, config.SweepBan}
+
+// Limits of wrong answers which admin can choose, negative means unlimited
+var attemptsPresets = []int64{-1, 3, 5, 10}
+
+// Values of cooldown after failed test which admin can choose
+var cooldownPresets = []int64{5 * 60, 3600, 24 * 3600, 7 * 24 * 3600}
+
+var returningModes = []string{config.ReturnTrust, config.ReturnVerify, config.ReturnAbsent}
+
+// Days of absence before returning user passes the test again which admin can choose
+var returnDaysPresets = []int64{7, 30, 90, 365}
+
+var invitedModes = []string{config.InviteVerify, config.InviteTrust, config.InviteApprove}
+
+// Joins per minute starting lockdown which admin can choose, negative means disabled
+var raidThresholdPresets = []int64{-1, 5, 10, 20, 50}
+
+var raidActions = []string{config.RaidKick, config.RaidApproval}
+
+// Quiet periods before lockdown is lifted which admin can choose
+var raidQuietPresets = []int64{5 * 60, 10 * 60, 30 * 60, 3600}
+
+// Messages before end of probation which admin can choose, negative means no probation
+var probationMessagesPresets = []int64{-1, 5, 10, 20, 50}
+
+// Durations of probation which admin can choose
+var probationTimePresets = []int64{3600, 6 * 3600, 24 * 3600, 3 * 24 * 3600, 7 * 24 * 3600}
+
+type inputKey struct {
+	UserID int
+}
+
+// pendingInput describes what text bot awaits from admin in private chat
+type pendingInput struct {
+	Kind   string
+	ChatID int64
+}
+
+// settingsCommand shows list of chats which can be configured by user
+func (b *Bot) settingsCommand(message *tg.Message) error {
+	if !message.Chat.IsPrivate() {
+		msg := tg.NewMessage(message.Chat.ID, fmt.Sprintf("Настройки доступны в личном чате с @%s", b.API.Self.UserName))
+		_, err := b.API.Send(msg)
+		if err != nil {
+			return errors.Wrapf(err, "Error sending message in settingsCommand to %d.", message.Chat.ID)
+		}
+		return nil
+	}
+
+	text, markup, err := b.settingsChatList(message.From.ID)
+	if err != nil {
+		return err
+	}
+	msg := tg.NewMessage(message.Chat.ID, text)
+	if markup != nil {
+		msg.ReplyMarkup = *markup
+	}
+	_, err = b.API.Send(msg)
+	if err != nil {
+		return errors.Wrapf(err, "Error sending settings menu to user %s.", names.ShortUserName(message.From))
+	}
+	return nil
+}
+
+// settingsAuth checks that user may open settings of chat
+func (b *Bot) settingsAuth(query *tg.CallbackQuery, args []string) bool {
+	if len(args) == 1 && args[0] == "list" {
+		return true
+	}
+	return b.authChatAdmin(1)(query, args)
+}
+
+// settingsCallback handles buttons of settings menu
+func (b *Bot) settingsCallback(query *tg.CallbackQuery, args []string) (callbackAnswer, error) {
+	var answer callbackAnswer
+	if query.Message == nil {
+		return answer, fmt.Errorf("Settings callback without message from user %s", names.ShortUserName(query.From))
+	}
+
+	if args[0] == "list" {
+		text, markup, err := b.settingsChatList(query.From.ID)
+		if err != nil {
+			return answer, err
+		}
+		return answer, b.editMenu(query.Message, text, markup)
+	}
+
+	// Chat was checked in settingsAuth
+	chatID, _ := strconv.ParseInt(args[1], 10, 64)
+
+	settings := b.chatSettings(chatID)
+	switch args[0] {
+	case "chat":
+		// Only show menu
+	case "spam":
+		settings.SpamCheck = !settings.SpamCheck
+	case "challenge":
+		settings.Challenge = nextString(challenge.Names(), settings.Challenge)
+	case "timeout":
+		settings.ConfirmTimeout = nextInt64(timeoutPresets, settings.ConfirmTimeout)
+	case "attempts":
+		settings.MaxAttempts = int(nextInt64(attemptsPresets, int64(settings.MaxAttempts)))
+	case "cooldown":
+		settings.Cooldown = nextInt64(cooldownPresets, settings.Cooldown)
+	case "sweep":
+		settings.SweepAction = nextString(sweepActions, settings.SweepAction)
+	case "returning":
+		settings.Returning = nextString(returningModes, settings.Returning)
+	case "returndays":
+		settings.ReturnDays = int(nextInt64(returnDaysPresets, int64(settings.ReturnDays)))
+	case "invited":
+		settings.Invited = nextString(invitedModes, settings.Invited)
+	case "raid":
+		settings.RaidThreshold = int(nextInt64(raidThresholdPresets, int64(settings.RaidThreshold)))
+	case "raidaction":
+		settings.RaidAction = nextString(raidActions, settings.RaidAction)
+	case "raidquiet":
+		settings.RaidQuiet = nextInt64(raidQuietPresets, settings.RaidQuiet)
+	case "probation":
+		settings.ProbationMessages = int(nextInt64(probationMessagesPresets, int64(settings.ProbationMessages)))
+	case "probationtime":
+		settings.ProbationTime = nextInt64(probationTimePresets, settings.ProbationTime)
+	case "verification":
+		settings.Verification = nextString(verificationModes, settings.Verification)
+	case "welcome":
+		b.Memo.Set(inputKey{UserID: query.From.ID}, pendingInput{Kind: "welcome", ChatID: chatID})
+		text := "Отправьте новый текст приветствия. {user} будет заменено на имя пользователя.\nОтправьте «-», чтобы вернуть стандартное приветствие."
+		_, err := b.API.Send(tg.NewMessage(query.Message.Chat.ID, text))
+		if err != nil {
+			return answer, errors.Wrapf(err, "Error sending welcome prompt to user %s.", names.ShortUserName(query.From))
+		}
+		return answer, nil
+	default:
+		return answer, fmt.Errorf("Unknown settings action %s from user %s", args[0], names.ShortUserName(query.From))
+	}
+
+	if args[0] != "chat" {
+		err := b.DB.UpdateChatSettings(chatID, settings)
+		if err != nil {
+			return answer, errors.Wrapf(err, "Error save settings of chat %d", chatID)
+		}
+		answer.Text = "Сохранено"
+	}
+
+	text, markup := b.settingsChatMenu(chatID, settings)
+	return answer, b.editMenu(query.Message, text, markup)
+}
+
+// settingsInput handles text awaited from admin. Returns false if text was not awaited.
+func (b *Bot) settingsInput(message *tg.Message) (bool, error) {
+	key := inputKey{UserID: message.From.ID}
+	mi, err := b.Memo.Get(key)
+	if err != nil {
+		return false, nil
+	}
+	input, ok := mi.(pendingInput)
+	if !ok {
+		return false, nil
+	}
+	b.Memo.Delete(key)
+
+	if !b.isChatAdmin(input.ChatID, message.From.ID) {
+		return true, fmt.Errorf("User %s is not admin of chat %d", names.ShortUserName(message.From), input.ChatID)
+	}
+
+	settings := b.chatSettings(input.ChatID)
+	switch input.Kind {
+	case "welcome":
+		settings.Welcome = message.Text
+		if strings.TrimSpace(message.Text) == "-" {
+			settings.Welcome = config.DefaultWelcome
+		}
+	default:
+		return true, fmt.Errorf("Unknown input kind %s from user %s", input.Kind, names.ShortUserName(message.From))
+	}
+
+	err = b.DB.UpdateChatSettings(input.ChatID, settings)
+	if err != nil {
+		return true, errors.Wrapf(err, "Error save settings of chat %d", input.ChatID)
+	}
+
+	text, markup := b.settingsChatMenu(input.ChatID, settings)
+	msg := tg.NewMessage(message.Chat.ID, text)
+	msg.ReplyMarkup = *markup
+	_, err = b.API.Send(msg)
+	if err != nil {
+		return true, errors.Wrapf(err, "Error sending settings menu to user %s.", names.ShortUserName(message.From))
+	}
+	return true, nil
+}
+
+func (b *Bot) settingsChatList(userID int) (string, *tg.InlineKeyboardMarkup, error) {
+	chats, err := b.DB.GetAdminChats(userID)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "Error getting chats of admin %d", userID)
+	}
+	rows := make([][]tg.InlineKeyboardButton, 0, len(chats))
+	for _, ch := range chats {
+		if !b.isChatAdmin(ch.ID, userID) {
+			continue
+		}
+		rows = append(rows, tg.NewInlineKeyboardRow(
+			tg.NewInlineKeyboardButtonData(ch.Title, settingsData("chat", ch.ID)),
+		))
+	}
+	if len(rows) == 0 {
+		return "Вы не администратор ни одного чата, где работает бот", nil, nil
+	}
+	markup := tg.NewInlineKeyboardMarkup(rows...)
+	return "Выберите чат для настройки", &markup, nil
+}
+
+func (b *Bot) settingsChatMenu(chatID int64, settings config.ChatSettings) (string, *tg.InlineKeyboardMarkup) {
+	spam := "выключена"
+	if settings.SpamCheck {
+		spam = "включена"
+	}
+	verification := "тест в личном чате с ботом"
+	switch settings.Verification {
+	case config.VerifyButton:
+		verification = "кнопка в чате"
+	case config.VerifyApproval:
+		verification = "одобрение администратором"
+	}
+	sweep := "сообщать администраторам"
+	if settings.SweepAction == config.SweepBan {
+		sweep = "банить и сообщать администраторам"
+	}
+	attempts := "без ограничений"
+	if settings.MaxAttempts > 0 {
+		attempts = strconv.Itoa(settings.MaxAttempts)
+	}
+	returning := "доверять"
+	switch settings.Returning {
+	case config.ReturnVerify:
+		returning = "проверять снова"
+	case config.ReturnAbsent:
+		returning = fmt.Sprintf("проверять снова после %d дн. отсутствия", settings.ReturnDays)
+	}
+	invited := "проходят проверку"
+	switch settings.Invited {
+	case config.InviteTrust:
+		invited = "доверять, если добавил администратор или доверенный участник"
+	case config.InviteApprove:
+		invited = "ждут одобрения, если добавил не администратор и не доверенный участник"
+	}
+	raid := "выключена"
+	if settings.RaidThreshold > 0 {
+		raid = fmt.Sprintf("от %d вступлений в минуту, %s, снятие через %s", settings.RaidThreshold, raidActionText(settings.RaidAction), formatDuration(settings.RaidQuiet))
+	}
+	probation := "нет"
+	if settings.ProbationMessages > 0 {
+		probation = fmt.Sprintf("только текст до %d сообщений или %s", settings.ProbationMessages, formatDuration(settings.ProbationTime))
+	}
+	text := fmt.Sprintf(
+		"Настройки чата %s\n\nПроверка: %s\nТест: %s\nВремя на прохождение теста: %s\nПопыток ответа: %s\nПовторное вступление через: %s\nВернувшиеся участники: %s\nДобавленные другими: %s\nБлокировка при наплыве: %s\nИспытательный срок: %s\nПроверка CAS: %s\nСпамеры среди участников: %s\n\nПриветствие:\n%s",
+		b.DB.GetChatTitle(chatID),
+		verification,
+		settings.Challenge,
+		formatDuration(settings.ConfirmTimeout),
+		attempts,
+		formatDuration(settings.Cooldown),
+		returning,
+		invited,
+		raid,
+		probation,
+		spam,
+		sweep,
+		settings.Welcome,
+	)
+
+	markup := tg.NewInlineKeyboardMarkup(
+		tg.NewInlineKeyboardRow(tg.NewInlineKeyboardButtonData("Сменить способ проверки", settingsData("verification", chatID))),
+		tg.NewInlineKeyboardRow(tg.NewInlineKeyboardButtonData("Сменить тест", settingsData("challenge", chatID))),
+		tg.NewInlineKeyboardRow(tg.NewInlineKeyboardButtonData("Сменить время на тест", settingsData("timeout", chatID))),
+		tg.NewInlineKeyboardRow(tg.NewInlineKeyboardButtonData("Сменить число попыток", settingsData("attempts", chatID))),
+		tg.NewInlineKeyboardRow(tg.NewInlineKeyboardButtonData("Сменить время до повторного вступления", settingsData("cooldown", chatID))),
+		tg.NewInlineKeyboardRow(
+			tg.NewInlineKeyboardButtonData("Вернувшиеся участники", settingsData("returning", chatID)),
+			tg.NewInlineKeyboardButtonData("Срок отсутствия", settingsData("returndays", chatID)),
+		),
+		tg.NewInlineKeyboardRow(tg.NewInlineKeyboardButtonData("Сменить проверку добавленных другими", settingsData("invited", chatID))),
+		tg.NewInlineKeyboardRow(
+			tg.NewInlineKeyboardButtonData("Порог блокировки", settingsData("raid", chatID)),
+			tg.NewInlineKeyboardButtonData("Действие", settingsData("raidaction", chatID)),
+			tg.NewInlineKeyboardButtonData("Снятие", settingsData("raidquiet", chatID)),
+		),
+		tg.NewInlineKeyboardRow(
+			tg.NewInlineKeyboardButtonData("Испытательный срок: сообщения", settingsData("probation", chatID)),
+			tg.NewInlineKeyboardButtonData("время", settingsData("probationtime", chatID)),
+		),
+		tg.NewInlineKeyboardRow(tg.NewInlineKeyboardButtonData("Вкл/выкл проверку CAS", settingsData("spam", chatID))),
+		tg.NewInlineKeyboardRow(tg.NewInlineKeyboardButtonData("Сменить действие со спамерами", settingsData("sweep", chatID))),
+		tg.NewInlineKeyboardRow(tg.NewInlineKeyboardButtonData("Изменить приветствие", settingsData("welcome", chatID))),
+		tg.NewInlineKeyboardRow(tg.NewInlineKeyboardButtonData("« К списку чатов", callbackData(settingsPrefix, "list"))),
+	)
+	return text, &markup
+}
+
+// editMenu replaces text and buttons of menu message
+func (b *Bot) editMenu(message *tg.Message, text string, markup *tg.InlineKeyboardMarkup) error {
+	edit := tg.NewEditMessageText(message.Chat.ID, message.MessageID, text)
+	edit.ReplyMarkup = markup
+	_, err := b.API.Send(edit)
+	if err != nil {
+		return errors.Wrapf(err, "Error edit menu message %d in chat %d.", message.MessageID, message.Chat.ID)
+	}
+	return nil
+}
+
+func settingsData(action string, chatID int64) string {
+	return callbackData(settingsPrefix, action, chatID)
+}
+
+// nextString returns element following current one in list
+func nextString(list []string, current string) string {
+	for i, v := range list {
+		if v == current {
+			return list[(i+1)%len(list)]
+		}
+	}
+	return list[0]
+}
+
+// nextInt64 returns element following current one in list
+func nextInt64(list []int64, current int64) int64 {
+	for i, v := range list {
+		if v == current {
+			return list[(i+1)%len(list)]
+		}
+	}
+	return list[0]
+}
@@ -0,0 +1,16 @@
+package bot
+
+import (
+	"tg-group-control-bot/internal/config"
+
+	"github.com/pkg/errors"
+)
+
+// chatSettings returns chat settings or defaults if settings cannot be read
+func (b *Bot) chatSettings(chatID int64) config.ChatSettings {
+	settings, err := b.DB.GetChatSettings(chatID)
+	if err != nil {
+		b.Log.Errorf("%+v", errors.Wrapf(err, "Failed get settings of chat %d", chatID))
+	}
+	return settings
+}
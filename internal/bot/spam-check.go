@@ -1,27 +1,36 @@
 package bot
 
 import (
-	"encoding/json"
-	"io/ioutil"
-	"net/http"
 	"strconv"
 	"time"
 
+	"tg-group-control-bot/internal/spam"
+
 	"github.com/pkg/errors"
 )
 
-type spamResult struct {
-	OK bool `json:"ok"`
-}
-
 type spamMemo struct {
 	OK bool
 	CT int64
 }
 
-// SpamCheck send request to Combot Anti-Spam service
-func (b *Bot) SpamCheck(id int) bool {
-	// First check memoized value
+// SpamCheck checks user in spam reputation sources if it enabled in chat.
+// Error means that some sources failed, verdict is still valid for policy of checker.
+func (b *Bot) SpamCheck(chatID int64, id int) (bool, error) {
+	if !b.chatSettings(chatID).SpamCheck {
+		return false, nil
+	}
+
+	// Local list is checked first because it does not depend on remote services
+	listed, err := b.DB.IsSpammer(id)
+	if err != nil {
+		b.Log.Errorf("%+v", errors.Wrapf(err, "Local spam list check of user %d", id))
+	}
+	if listed {
+		return true, nil
+	}
+
+	// Check memoized value
 	memoKey := "CAS" + strconv.Itoa(id)
 	if ms, err := b.Memo.Get(memoKey); err == nil {
 		// Try cast type
@@ -29,52 +38,61 @@ func (b *Bot) SpamCheck(id int) bool {
 			// Return if value not expired (3 hours)
 			if (mr.CT + 3600*3) > time.Now().Unix() {
 				// Return memoized result
-				return mr.OK
+				return mr.OK, nil
 			}
 		}
 	}
 
-	client := &http.Client{
-		Timeout: 3 * time.Second, // Response timeout up to 3 seconds
-		Transport: &http.Transport{
-			MaxIdleConnsPerHost: 20, // Allow 20 connections to one host
-		},
-	}
-	request, _ := http.NewRequest(http.MethodGet, "https://api.cas.chat/check?user_id="+strconv.Itoa(id), nil)
-
-	response, err := client.Do(request)
-
+	isSpammer, err := b.Spam.Check(id)
 	if err != nil {
-		b.Log.Errorf("%+v", errors.Wrap(err, "CAS: Request error"))
-		return false
+		// Verdict is not memoized to repeat failed checks next time
+		return isSpammer, errors.Wrapf(err, "Spam check of user %d", id)
 	}
 
-	if response == nil {
-		b.Log.Errorf("%+v", errors.New("CAS: Empty response"))
-		return false
-	}
+	// Memoize spam check value
+	b.Memo.Set(memoKey, spamMemo{
+		OK: isSpammer,
+		CT: time.Now().Unix(),
+	})
 
-	defer response.Body.Close()
-	body, err := ioutil.ReadAll(response.Body)
+	return isSpammer, nil
+}
 
+// loadSpamList loads list of spammers and checks that it may replace local list
+func (b *Bot) loadSpamList() ([]int, error) {
+	ids, err := spam.LoadList(b.Config.SpamList)
 	if err != nil {
-		b.Log.Errorf("%+v", errors.Wrap(err, "CAS: Body read error"))
-		return false
+		return nil, err
 	}
-
-	var spam spamResult
-	err = json.Unmarshal(body, &spam)
+	current, err := b.DB.CountSpammers()
 	if err != nil {
-		b.Log.Errorf("%+v", errors.Wrap(err, "CAS: JSON parsing error"))
-		b.Log.Errorln("JSON parsing error body. ", string(body))
-		return false
+		return nil, err
 	}
+	err = spam.CheckList(ids, current)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Local list of %d users is kept", current)
+	}
+	return ids, nil
+}
 
-	// Memoize CAS check value
-	b.Memo.Set(memoKey, spamMemo{
-		OK: spam.OK,
-		CT: time.Now().Unix(),
-	})
+// runSpamListRefresh periodically loads list of spammers to local storage
+func (b *Bot) runSpamListRefresh() {
+	if b.Config.SpamList == "" {
+		return
+	}
 
-	return spam.OK
+	ticker := time.NewTicker(b.Config.SpamListEvery)
+	defer ticker.Stop()
+	for {
+		ids, err := b.loadSpamList()
+		if err == nil {
+			err = b.DB.UpdateSpammers(ids)
+		}
+		if err != nil {
+			b.Log.Errorf("%+v", errors.Wrap(err, "Failed refresh spam list"))
+		} else {
+			b.Log.Infof("Loaded %d users from spam list", len(ids))
+		}
+		<-ticker.C
+	}
 }
@@ -0,0 +1,143 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"tg-group-control-bot/internal/config"
+	"tg-group-control-bot/internal/names"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/pkg/errors"
+)
+
+// Pause between checks of members to not overload spam services
+const sweepPause = 100 * time.Millisecond
+
+// runSweep periodically checks members of all chats for spammers.
+// Non-positive interval disables periodic sweep, /sweep still works.
+func (b *Bot) runSweep() {
+	if b.Config.SweepEvery <= 0 {
+		b.Log.Info("Periodic sweep is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(b.Config.SweepEvery)
+	defer ticker.Stop()
+	for range ticker.C {
+		ids, err := b.DB.GetChatIDs()
+		if err != nil {
+			b.Log.Errorf("%+v", err)
+		}
+		for _, id := range ids {
+			if _, err := b.sweepChat(id); err != nil {
+				b.Log.Errorf("%+v", err)
+			}
+		}
+	}
+}
+
+// sweepChat checks members of chat for spammers and returns number of found spammers
+func (b *Bot) sweepChat(chatID int64) (int, error) {
+	settings := b.chatSettings(chatID)
+	if !settings.SpamCheck {
+		return 0, nil
+	}
+
+	ch, err := b.DB.GetChatInfo(chatID)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Failed get chat %d for sweep", chatID)
+	}
+
+	admins := make(map[int]bool)
+	for _, adm := range ch.Admins {
+		admins[adm] = true
+	}
+
+	found := make([]string, 0)
+	for _, cu := range ch.Users {
+		if admins[cu.ID] {
+			continue
+		}
+		time.Sleep(sweepPause)
+		// Members are punished only for certain verdict, failure of spam service must not ban everyone
+		isSpammer, err := b.SpamCheck(chatID, cu.ID)
+		if err != nil {
+			b.Log.Errorf("%+v", errors.Wrapf(err, "Member %d of chat %d is skipped by sweep", cu.ID, chatID))
+			continue
+		}
+		if !isSpammer {
+			continue
+		}
+
+		name := fmt.Sprintf("%d", cu.ID)
+		if u, err := b.DB.GetUser(cu.ID); err == nil {
+			name = names.LocalUserShortName(u) + " (" + name + ")"
+		}
+
+		if settings.SweepAction == config.SweepBan {
+			err := b.banSpammer(chatID, cu.ID)
+			if err != nil {
+				b.Log.Errorf("%+v", err)
+				name += " — не удалось забанить"
+			} else {
+				name += " — забанен"
+				b.propagateBan(chatID, cu.ID, "spam")
+			}
+		}
+		found = append(found, name)
+	}
+
+	b.Log.Infof("Sweep of chat %d found %d spammers among %d members", chatID, len(found), len(ch.Users))
+	if len(found) > 0 {
+		b.notifyAdmins(chatID, fmt.Sprintf("В чате %s найдены спамеры:\n%s", b.DB.GetChatTitle(chatID), strings.Join(found, "\n")))
+	}
+	return len(found), nil
+}
+
+// banSpammer bans user in chat forever and removes from chat members
+func (b *Bot) banSpammer(chatID int64, userID int) error {
+	resp, err := b.API.KickChatMember(tg.KickChatMemberConfig{
+		ChatMemberConfig: tg.ChatMemberConfig{
+			ChatID: chatID,
+			UserID: userID,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("Failed ban spammer %d in chat %d with code %d and error %s", userID, chatID, resp.ErrorCode, resp.Description)
+	}
+	err = b.DB.RemoveChatUser(chatID, userID)
+	if err != nil {
+		return errors.Wrapf(err, "Failed remove spammer %d from chat %d", userID, chatID)
+	}
+	return nil
+}
+
+// sweepCommand checks members of chat on demand
+func (b *Bot) sweepCommand(message *tg.Message) error {
+	if message.Chat.IsPrivate() {
+		_, err := b.API.Send(tg.NewMessage(message.Chat.ID, "Отправьте эту команду в группу, участников которой нужно проверить"))
+		if err != nil {
+			return errors.Wrapf(err, "Error sending message in sweepCommand to %d.", message.Chat.ID)
+		}
+		return nil
+	}
+	if !b.isChatAdmin(message.Chat.ID, message.From.ID) {
+		return fmt.Errorf("User %s is not admin of chat %s and cannot sweep members", names.ShortUserName(message.From), names.ChatName(message.Chat))
+	}
+
+	text := "Проверка участников отключена в настройках"
+	if b.chatSettings(message.Chat.ID).SpamCheck {
+		n, err := b.sweepChat(message.Chat.ID)
+		if err != nil {
+			return err
+		}
+		text = fmt.Sprintf("Проверка участников завершена, найдено спамеров: %d", n)
+	}
+	_, err := b.API.Send(tg.NewMessage(message.Chat.ID, text))
+	if err != nil {
+		return errors.Wrapf(err, "Error sending message in sweepCommand to %d.", message.Chat.ID)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package bot
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+
+	"tg-group-control-bot/internal/names"
+
+	tg "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/pkg/errors"
+)
+
+// Callback data of verification button is verify:<userID>:<nonce>
+const verifyPrefix = "verify"
+
+// newNonce returns random secret for verification button
+func newNonce() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// verifyAuth allows to press the button only to user which joined the chat
+func (b *Bot) verifyAuth(query *tg.CallbackQuery, args []string) bool {
+	if len(args) < 2 || query.Message == nil {
+		return false
+	}
+	userID, err := strconv.Atoi(args[0])
+	if err != nil {
+		return false
+	}
+	return userID == query.From.ID
+}
+
+// verifyCallback confirms user which pressed the button in group chat
+func (b *Bot) verifyCallback(query *tg.CallbackQuery, args []string) (callbackAnswer, error) {
+	chatID := query.Message.Chat.ID
+	cu, err := b.DB.GetChatUser(chatID, query.From.ID)
+	if err != nil {
+		return callbackAnswer{}, errors.Wrapf(err, "Failed get user %s in chat %d", names.ShortUserName(query.From), chatID)
+	}
+	if cu.Confirmed || cu.Nonce == "" || cu.Nonce != args[1] {
+		return callbackAnswer{Text: "Кнопка устарела", Alert: true}, nil
+	}
+
+	if b.inLockdown(chatID) {
+		// Button is too easy during raid, user passes captcha in private chat instead
+		timeout := b.chatSettings(chatID).ConfirmTimeout
+		return callbackAnswer{URL: b.testLink(chatID, query.From.ID, timeout)}, nil
+	}
+
+	err = b.confirmUser(chatID, query.From)
+	if err != nil {
+		return callbackAnswer{}, err
+	}
+	return callbackAnswer{Text: "Вы получили доступ к чату"}, nil
+}
@@ -0,0 +1,68 @@
+package challenge
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+)
+
+// Arithmetic asks to solve simple arithmetic expression
+type Arithmetic struct {
+	// Number of answer variants shown as buttons
+	Options int
+}
+
+func init() {
+	Register(Arithmetic{Options: 4})
+}
+
+// Name returns identifier of challenge
+func (a Arithmetic) Name() string {
+	return "arithmetic"
+}
+
+// Generate returns task with random expression
+func (a Arithmetic) Generate() Task {
+	x, y := rand.Intn(20)+1, rand.Intn(20)+1
+	var op string
+	var result int
+	switch rand.Intn(3) {
+	case 0:
+		op, result = "+", x+y
+	case 1:
+		// Avoid negative results
+		if x < y {
+			x, y = y, x
+		}
+		op, result = "-", x-y
+	default:
+		x, y = x%10+1, y%10+1
+		op, result = "×", x*y
+	}
+
+	answer := strconv.Itoa(result)
+	options := []string{answer}
+	for len(options) < a.Options {
+		variant := strconv.Itoa(result + rand.Intn(21) - 10)
+		if !contains(options, variant) && variant[0] != '-' {
+			options = append(options, variant)
+		}
+	}
+
+	return Task{
+		Kind:     a.Name(),
+		Question: fmt.Sprintf("%d %s %d = ?", x, op, y),
+		Hint:     "Отправьте число или нажмите на кнопку с ответом.",
+		Answers:  []string{answer},
+		Options:  shuffle(options),
+	}
+}
+
+func contains(list []string, str string) bool {
+	for _, v := range list {
+		if v == str {
+			return true
+		}
+	}
+	return false
+}
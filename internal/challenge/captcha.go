@@ -0,0 +1,173 @@
+package challenge
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"math/rand"
+	"strings"
+)
+
+// Characters of captcha. Similar looking characters are excluded.
+const captchaAlphabet = "2345678ACEFHKMNPRTUXY"
+
+// Glyphs of captcha characters 5x7 pixels
+var glyphs = map[rune][7]string{
+	'2': {" ### ", "#   #", "    #", "   # ", "  #  ", " #   ", "#####"},
+	'3': {"#### ", "    #", "    #", " ### ", "    #", "    #", "#### "},
+	'4': {"   # ", "  ## ", " # # ", "#  # ", "#####", "   # ", "   # "},
+	'5': {"#####", "#    ", "#### ", "    #", "    #", "#   #", " ### "},
+	'6': {" ### ", "#    ", "#    ", "#### ", "#   #", "#   #", " ### "},
+	'7': {"#####", "    #", "   # ", "  #  ", " #   ", " #   ", " #   "},
+	'8': {" ### ", "#   #", "#   #", " ### ", "#   #", "#   #", " ### "},
+	'A': {" ### ", "#   #", "#   #", "#####", "#   #", "#   #", "#   #"},
+	'C': {" ### ", "#   #", "#    ", "#    ", "#    ", "#   #", " ### "},
+	'E': {"#####", "#    ", "#    ", "#### ", "#    ", "#    ", "#####"},
+	'F': {"#####", "#    ", "#    ", "#### ", "#    ", "#    ", "#    "},
+	'H': {"#   #", "#   #", "#   #", "#####", "#   #", "#   #", "#   #"},
+	'K': {"#   #", "#  # ", "# #  ", "##   ", "# #  ", "#  # ", "#   #"},
+	'M': {"#   #", "## ##", "# # #", "# # #", "#   #", "#   #", "#   #"},
+	'N': {"#   #", "##  #", "# # #", "#  ##", "#   #", "#   #", "#   #"},
+	'P': {"#### ", "#   #", "#   #", "#### ", "#    ", "#    ", "#    "},
+	'R': {"#### ", "#   #", "#   #", "#### ", "# #  ", "#  # ", "#   #"},
+	'T': {"#####", "  #  ", "  #  ", "  #  ", "  #  ", "  #  ", "  #  "},
+	'U': {"#   #", "#   #", "#   #", "#   #", "#   #", "#   #", " ### "},
+	'X': {"#   #", "#   #", " # # ", "  #  ", " # # ", "#   #", "#   #"},
+	'Y': {"#   #", "#   #", " # # ", "  #  ", "  #  ", "  #  ", "  #  "},
+}
+
+const (
+	captchaScale  = 6
+	captchaMargin = 20
+)
+
+// Captcha asks to enter characters from distorted image
+type Captcha struct {
+	// Number of characters on image
+	Length int
+	// Number of attempts before image is replaced
+	Retries int
+}
+
+func init() {
+	Register(Captcha{Length: 5, Retries: 3})
+}
+
+// Name returns identifier of challenge
+func (c Captcha) Name() string {
+	return "captcha"
+}
+
+// Generate returns task with random characters
+func (c Captcha) Generate() Task {
+	var text strings.Builder
+	for i := 0; i < c.Length; i++ {
+		text.WriteByte(captchaAlphabet[rand.Intn(len(captchaAlphabet))])
+	}
+
+	return Task{
+		Kind:     c.Name(),
+		Question: "Введите символы с картинки.",
+		Hint:     "Регистр букв не важен.",
+		Answers:  []string{text.String()},
+		Retries:  c.Retries,
+	}
+}
+
+// Image renders characters of task to PNG image
+func (c Captcha) Image(task Task) ([]byte, error) {
+	text := ""
+	if len(task.Answers) > 0 {
+		text = task.Answers[0]
+	}
+
+	cell := 6 * captchaScale
+	width := len(text)*cell + 2*captchaMargin
+	height := 7*captchaScale + 2*captchaMargin
+	src := image.NewRGBA(image.Rect(0, 0, width, height))
+	fill(src, color.RGBA{245, 245, 240, 255})
+
+	// Background noise
+	for i := 0; i < width*height/15; i++ {
+		src.Set(rand.Intn(width), rand.Intn(height), randomColor(120, 220))
+	}
+
+	for i, ch := range text {
+		drawGlyph(src, glyphs[ch], captchaMargin+i*cell+rand.Intn(7)-3, captchaMargin+rand.Intn(13)-6, randomColor(0, 110))
+	}
+
+	// Lines crossing characters
+	for i := 0; i < 4; i++ {
+		drawLine(src, 0, rand.Intn(height), width-1, rand.Intn(height), randomColor(40, 140))
+	}
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, wave(src))
+	return buf.Bytes(), err
+}
+
+func drawGlyph(img *image.RGBA, glyph [7]string, x, y int, c color.Color) {
+	// Slant characters randomly
+	slant := rand.Float64()*0.4 - 0.2
+	for row, line := range glyph {
+		shift := int(slant * float64((7-row)*captchaScale))
+		for col, px := range line {
+			if px != '#' {
+				continue
+			}
+			for dy := 0; dy < captchaScale; dy++ {
+				for dx := 0; dx < captchaScale; dx++ {
+					img.Set(x+col*captchaScale+dx+shift, y+row*captchaScale+dy, c)
+				}
+			}
+		}
+	}
+}
+
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.Color) {
+	steps := x1 - x0
+	for i := 0; i <= steps; i++ {
+		y := y0 + (y1-y0)*i/steps
+		img.Set(x0+i, y, c)
+		img.Set(x0+i, y+1, c)
+	}
+}
+
+// wave distorts image with sine waves
+func wave(src *image.RGBA) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(b)
+	fill(dst, color.RGBA{245, 245, 240, 255})
+
+	amplitude := 2 + rand.Float64()*2
+	period := 40 + rand.Float64()*40
+	phase := rand.Float64() * 2 * math.Pi
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			sy := y + int(amplitude*math.Sin(float64(x)/period*2*math.Pi+phase))
+			sx := x + int(amplitude/2*math.Cos(float64(y)/period*2*math.Pi+phase))
+			if image.Pt(sx, sy).In(b) {
+				dst.Set(x, y, src.At(sx, sy))
+			}
+		}
+	}
+	return dst
+}
+
+func fill(img *image.RGBA, c color.Color) {
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			img.Set(x, y, c)
+		}
+	}
+}
+
+func randomColor(min, max int) color.RGBA {
+	level := func() uint8 {
+		return uint8(min + rand.Intn(max-min))
+	}
+	return color.RGBA{level(), level(), level(), 255}
+}
@@ -0,0 +1,112 @@
+package challenge
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default is the name of the challenge used when chat has no configured one
+const Default = "question"
+
+// Challenge generates tasks which new chat members must solve
+type Challenge interface {
+	// Name returns identifier of challenge used in chat configuration
+	Name() string
+	// Generate returns new task for user
+	Generate() Task
+}
+
+// Imager is implemented by challenges which question is shown as image
+type Imager interface {
+	// Image returns PNG image of task
+	Image(task Task) ([]byte, error)
+}
+
+// Task is a generated instance of challenge
+type Task struct {
+	Kind     string   `json:"Kind" bson:"Kind"`
+	Question string   `json:"Question" bson:"Question"`
+	Hint     string   `json:"Hint" bson:"Hint"`
+	Answers  []string `json:"Answers" bson:"Answers"`
+	// Variants of answer shown as buttons
+	Options []string `json:"Options" bson:"Options"`
+	// Number of wrong answers before task is replaced, 0 means unlimited
+	Retries  int `json:"Retries" bson:"Retries"`
+	Attempts int `json:"Attempts" bson:"Attempts"`
+}
+
+// Empty returns true if task was not generated
+func (t Task) Empty() bool {
+	return t.Kind == ""
+}
+
+// Exhausted returns true if user has no more attempts for task
+func (t Task) Exhausted() bool {
+	return t.Retries > 0 && t.Attempts >= t.Retries
+}
+
+// Check validates user's answer
+func (t Task) Check(answer string) bool {
+	a := normalize(answer)
+	for _, v := range t.Answers {
+		if normalize(v) == a {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	registry = make(map[string]Challenge)
+	mutex    sync.RWMutex
+)
+
+func init() {
+	rand.Seed(time.Now().UnixNano())
+}
+
+// Register adds challenge to list of available challenges
+func Register(c Challenge) {
+	mutex.Lock()
+	registry[c.Name()] = c
+	mutex.Unlock()
+}
+
+// Get returns challenge by name or default challenge if name is unknown
+func Get(name string) Challenge {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	if c, exist := registry[name]; exist {
+		return c
+	}
+	return registry[Default]
+}
+
+// Names returns names of all registered challenges
+func Names() []string {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	list := make([]string, 0, len(registry))
+	for name := range registry {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// shuffle returns list in random order
+func shuffle(list []string) []string {
+	rand.Shuffle(len(list), func(i, j int) {
+		list[i], list[j] = list[j], list[i]
+	})
+	return list
+}
+
+func normalize(str string) string {
+	return strings.ToLower(strings.TrimSpace(str))
+}
@@ -0,0 +1,59 @@
+package challenge
+
+import "math/rand"
+
+type emoji struct {
+	Symbol string
+	// Name in genitive case
+	Name string
+}
+
+var emojis = []emoji{
+	{"🍎", "яблока"},
+	{"🍌", "банана"},
+	{"🍒", "вишни"},
+	{"🍇", "винограда"},
+	{"🍋", "лимона"},
+	{"🍓", "клубники"},
+	{"🥕", "моркови"},
+	{"🍉", "арбуза"},
+	{"🍐", "груши"},
+	{"🍄", "гриба"},
+	{"🐱", "кошки"},
+	{"🐶", "собаки"},
+	{"🚗", "машины"},
+	{"⚽", "мяча"},
+	{"🌵", "кактуса"},
+}
+
+// Emoji asks to choose emoji by its name
+type Emoji struct {
+	// Number of emojis shown as buttons
+	Options int
+}
+
+func init() {
+	Register(Emoji{Options: 6})
+}
+
+// Name returns identifier of challenge
+func (e Emoji) Name() string {
+	return "emoji"
+}
+
+// Generate returns task with random set of emojis
+func (e Emoji) Generate() Task {
+	perm := rand.Perm(len(emojis))[:e.Options]
+	options := make([]string, 0, e.Options)
+	for _, i := range perm {
+		options = append(options, emojis[i].Symbol)
+	}
+	answer := emojis[perm[rand.Intn(len(perm))]]
+
+	return Task{
+		Kind:     e.Name(),
+		Question: "Нажмите на кнопку с изображением " + answer.Name + ".",
+		Answers:  []string{answer.Symbol},
+		Options:  options,
+	}
+}
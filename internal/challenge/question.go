@@ -0,0 +1,49 @@
+package challenge
+
+import "math/rand"
+
+// Item is a question with accepted answers
+type Item struct {
+	Text    string   `json:"Text" bson:"Text"`
+	Answers []string `json:"Answers" bson:"Answers"`
+}
+
+// Question is a challenge with question randomly chosen from the list
+type Question struct {
+	Hint  string
+	Items []Item
+}
+
+// DefaultQuestions used when chat has no own questions
+var DefaultQuestions = []Item{{
+	Text:    "Вы бот?",
+	Answers: []string{"нет", "no"},
+}}
+
+func init() {
+	Register(Question{
+		Hint:  "Ответьте одним словом.",
+		Items: DefaultQuestions,
+	})
+}
+
+// Name returns identifier of challenge
+func (q Question) Name() string {
+	return Default
+}
+
+// Generate returns task with random question from the list
+func (q Question) Generate() Task {
+	items := q.Items
+	if len(items) == 0 {
+		items = DefaultQuestions
+	}
+	item := items[rand.Intn(len(items))]
+
+	return Task{
+		Kind:     q.Name(),
+		Question: item.Text,
+		Hint:     q.Hint,
+		Answers:  item.Answers,
+	}
+}
@@ -1,26 +1,42 @@
 package config
 
+import (
+	"time"
+
+	"tg-group-control-bot/internal/challenge"
+)
+
 // Config is main application configuration struct
 type Config struct {
-	Debug         bool   `env:"DEBUG" envDefault:"false"`
-	TelegramDebug bool   `env:"TG_DEBUG" envDefault:"false"`
-	BotToken      string `env:"BOT_TOKEN,required"`
-	MongoURL      string `env:"MONGO_URL,required"`
+	Debug         bool          `env:"DEBUG" envDefault:"false"`
+	TelegramDebug bool          `env:"TG_DEBUG" envDefault:"false"`
+	BotToken      string        `env:"BOT_TOKEN,required"`
+	MongoURL      string        `env:"MONGO_URL,required"`
+	LinkSecret    string        `env:"LINK_SECRET"`
+	CASURL        string        `env:"CAS_URL" envDefault:"https://api.cas.chat"`
+	SpamPolicy    string        `env:"SPAM_POLICY" envDefault:"any"`
+	SpamBlocklist []int         `env:"SPAM_BLOCKLIST" envSeparator:","`
+	SpamList      string        `env:"SPAM_LIST"`
+	SpamListEvery time.Duration `env:"SPAM_LIST_INTERVAL" envDefault:"6h"`
+	SweepEvery    time.Duration `env:"SWEEP_INTERVAL" envDefault:"24h"`
+	RootUserID    int           `env:"ROOT_USER_ID"`
 }
 
 // User describes all meta data
 type User struct {
-	ID        int     `json:"ID" bson:"ID"`
-	FirstName string  `json:"FirstName" bson:"FirstName"`
-	LastName  string  `json:"LastName" bson:"LastName"`
-	UserName  string  `json:"UserName" bson:"UserName"`
-	Language  string  `json:"Language" bson:"Language"`
-	Bot       bool    `json:"Bot" bson:"Bot"`
-	Banned    bool    `json:"Banned" bson:"Banned"`
-	BanDate   int64   `json:"BanDate" bson:"BanDate"`
-	RegDate   int64   `json:"RegDate" bson:"RegDate"`
-	UsageDate int64   `json:"UsageDate" bson:"UsageDate"`
-	Chats     []int64 `json:"Chats" bson:"Chats"`
+	ID         int     `json:"ID" bson:"ID"`
+	FirstName  string  `json:"FirstName" bson:"FirstName"`
+	LastName   string  `json:"LastName" bson:"LastName"`
+	UserName   string  `json:"UserName" bson:"UserName"`
+	Language   string  `json:"Language" bson:"Language"`
+	Bot        bool    `json:"Bot" bson:"Bot"`
+	Banned     bool    `json:"Banned" bson:"Banned"`
+	BanDate    int64   `json:"BanDate" bson:"BanDate"`
+	BanReason  string  `json:"BanReason" bson:"BanReason"`
+	RegDate    int64   `json:"RegDate" bson:"RegDate"`
+	UsageDate  int64   `json:"UsageDate" bson:"UsageDate"`
+	Chats      []int64 `json:"Chats" bson:"Chats"`
+	ActiveChat int64   `json:"ActiveChat" bson:"ActiveChat"`
 }
 
 // String displays a simple text version of a user.
@@ -41,20 +57,253 @@ func (u *User) String() string {
 
 // Chat describes chat where bot placed
 type Chat struct {
-	ID       int64      `json:"ID" bson:"ID"`
-	Users    []ChatUser `json:"Users" bson:"Users"`
-	Title    string     `json:"Title" bson:"Title"`
-	UserName string     `json:"UserName" bson:"UserName"`
-	Type     string     `json:"Type" bson:"Type"`
-	Admins   []int      `json:"Admins" bson:"Admins"`
+	ID        int64            `json:"ID" bson:"ID"`
+	Users     []ChatUser       `json:"Users" bson:"Users"`
+	Title     string           `json:"Title" bson:"Title"`
+	UserName  string           `json:"UserName" bson:"UserName"`
+	Type      string           `json:"Type" bson:"Type"`
+	Admins    []int            `json:"Admins" bson:"Admins"`
+	Settings  *ChatSettings    `json:"Settings,omitempty" bson:"Settings,omitempty"`
+	Questions []challenge.Item `json:"Questions" bson:"Questions"`
+	// Rights of admins received from telegram
+	AdminRights []AdminRights `json:"AdminRights" bson:"AdminRights"`
+	// Members which may add users without verification
+	Trusted []int `json:"Trusted" bson:"Trusted"`
+	// Bots which may be added by any member
+	AllowedBots []int `json:"AllowedBots" bson:"AllowedBots"`
+	// Chat is under raid, new users are not allowed to pass the test
+	Lockdown bool `json:"Lockdown" bson:"Lockdown"`
+}
+
+// AdminRights describes rights of chat admin
+type AdminRights struct {
+	ID                 int    `json:"ID" bson:"ID"`
+	Status             string `json:"Status" bson:"Status"`
+	CanChangeInfo      bool   `json:"CanChangeInfo" bson:"CanChangeInfo"`
+	CanDeleteMessages  bool   `json:"CanDeleteMessages" bson:"CanDeleteMessages"`
+	CanInviteUsers     bool   `json:"CanInviteUsers" bson:"CanInviteUsers"`
+	CanRestrictMembers bool   `json:"CanRestrictMembers" bson:"CanRestrictMembers"`
+	CanPinMessages     bool   `json:"CanPinMessages" bson:"CanPinMessages"`
+	CanPromoteMembers  bool   `json:"CanPromoteMembers" bson:"CanPromoteMembers"`
+}
+
+// CanManageBot returns true if admin may configure bot and moderate members
+func (r AdminRights) CanManageBot() bool {
+	return r.Status == "creator" || r.CanRestrictMembers
+}
+
+// ChatSettings describes behaviour of bot in chat
+type ChatSettings struct {
+	// Name of challenge for new users
+	Challenge string `json:"Challenge" bson:"Challenge"`
+	// Seconds given to new user to pass the test
+	ConfirmTimeout int64 `json:"ConfirmTimeout" bson:"ConfirmTimeout"`
+	// Check new users in Combot Anti-Spam service
+	SpamCheck bool `json:"SpamCheck" bson:"SpamCheck"`
+	// Greeting for new user. {user} is replaced with user name
+	Welcome string `json:"Welcome" bson:"Welcome"`
+	// Way of passing the test: VerifyPrivate, VerifyButton or VerifyApproval
+	Verification string `json:"Verification" bson:"Verification"`
+	// Number of wrong answers before user is kicked, negative means unlimited
+	MaxAttempts int `json:"MaxAttempts" bson:"MaxAttempts"`
+	// Seconds before kicked user can join again
+	Cooldown int64 `json:"Cooldown" bson:"Cooldown"`
+	// Action with spammers found among members: SweepReport or SweepBan
+	SweepAction string `json:"SweepAction" bson:"SweepAction"`
+	// Handling of confirmed users which left and joined again: ReturnTrust, ReturnVerify or ReturnAbsent
+	Returning string `json:"Returning" bson:"Returning"`
+	// Days of absence after which returning user passes the test again with ReturnAbsent
+	ReturnDays int `json:"ReturnDays" bson:"ReturnDays"`
+	// Handling of users added by other members: InviteVerify, InviteTrust or InviteApprove
+	Invited string `json:"Invited" bson:"Invited"`
+	// Joins per minute which start lockdown, negative means that lockdown is disabled
+	RaidThreshold int `json:"RaidThreshold" bson:"RaidThreshold"`
+	// Action with users joined during lockdown: RaidKick or RaidApproval
+	RaidAction string `json:"RaidAction" bson:"RaidAction"`
+	// Seconds without joins after which lockdown is lifted
+	RaidQuiet int64 `json:"RaidQuiet" bson:"RaidQuiet"`
+	// Messages which verified user sends before media and links are allowed, negative means no probation
+	ProbationMessages int `json:"ProbationMessages" bson:"ProbationMessages"`
+	// Seconds after verification when media and links are allowed regardless of messages
+	ProbationTime int64 `json:"ProbationTime" bson:"ProbationTime"`
+}
+
+// Actions with users joined during lockdown
+const (
+	// Kick user until lockdown is lifted
+	RaidKick = "kick"
+	// Restrict user until admin approves it
+	RaidApproval = "approval"
+)
+
+// Handling of users added by other members
+const (
+	// Added user passes the test like users joined by themselves
+	InviteVerify = "verify"
+	// Added user is confirmed if added by admin or trusted member, otherwise passes the test
+	InviteTrust = "trust"
+	// Added user is confirmed if added by admin or trusted member, otherwise waits for approval of admin
+	InviteApprove = "approve"
+)
+
+// Handling of confirmed users which joined chat again
+const (
+	// Returning user stays confirmed
+	ReturnTrust = "trust"
+	// Returning user passes the test again
+	ReturnVerify = "verify"
+	// Returning user passes the test again if was absent longer than ReturnDays
+	ReturnAbsent = "absent"
+)
+
+// Actions with spammers found among chat members
+const (
+	// Send list of spammers to admins
+	SweepReport = "report"
+	// Ban spammers and send list to admins
+	SweepBan = "ban"
+)
+
+// Ways of user verification
+const (
+	// User passes the test in private chat with bot
+	VerifyPrivate = "private"
+	// User presses the button in group chat
+	VerifyButton = "button"
+	// Admin approves or rejects user
+	VerifyApproval = "approval"
+)
+
+// DefaultWelcome is greeting for new user used when chat has no own greeting
+const DefaultWelcome = "Привет {user}\nТы в режиме только для чтения. Для того, чтобы получить полные права в этом чате надо пройти тест.\nНажми кнопку под этим сообщением, чтобы пройти тест."
+
+// DefaultChatSettings returns settings for chat which was not configured
+func DefaultChatSettings() ChatSettings {
+	return ChatSettings{
+		Challenge:         challenge.Default,
+		ConfirmTimeout:    24 * 60 * 60,
+		SpamCheck:         true,
+		Welcome:           DefaultWelcome,
+		Verification:      VerifyPrivate,
+		MaxAttempts:       5,
+		Cooldown:          60 * 60,
+		SweepAction:       SweepReport,
+		Returning:         ReturnTrust,
+		ReturnDays:        30,
+		Invited:           InviteVerify,
+		RaidThreshold:     -1,
+		RaidAction:        RaidKick,
+		RaidQuiet:         10 * 60,
+		ProbationMessages: -1,
+		ProbationTime:     24 * 60 * 60,
+	}
+}
+
+// Fill replaces empty values with defaults
+func (cs *ChatSettings) Fill() {
+	d := DefaultChatSettings()
+	if cs.Challenge == "" {
+		cs.Challenge = d.Challenge
+	}
+	if cs.ConfirmTimeout <= 0 {
+		cs.ConfirmTimeout = d.ConfirmTimeout
+	}
+	if cs.Welcome == "" {
+		cs.Welcome = d.Welcome
+	}
+	if cs.Verification == "" {
+		cs.Verification = d.Verification
+	}
+	if cs.MaxAttempts == 0 {
+		cs.MaxAttempts = d.MaxAttempts
+	}
+	if cs.Cooldown <= 0 {
+		cs.Cooldown = d.Cooldown
+	}
+	if cs.SweepAction == "" {
+		cs.SweepAction = d.SweepAction
+	}
+	if cs.Returning == "" {
+		cs.Returning = d.Returning
+	}
+	if cs.ReturnDays <= 0 {
+		cs.ReturnDays = d.ReturnDays
+	}
+	if cs.Invited == "" {
+		cs.Invited = d.Invited
+	}
+	if cs.RaidThreshold == 0 {
+		cs.RaidThreshold = d.RaidThreshold
+	}
+	if cs.RaidAction == "" {
+		cs.RaidAction = d.RaidAction
+	}
+	if cs.RaidQuiet <= 0 {
+		cs.RaidQuiet = d.RaidQuiet
+	}
+	if cs.ProbationMessages == 0 {
+		cs.ProbationMessages = d.ProbationMessages
+	}
+	if cs.ProbationTime <= 0 {
+		cs.ProbationTime = d.ProbationTime
+	}
 }
 
 // ChatUser describes user in chat
 type ChatUser struct {
-	ID         int    `json:"ID" bson:"ID"`
-	Confirmed  bool   `json:"Confirmed" bson:"Confirmed"`
-	ConfirmMsg Ref    `json:"ConfirmMsg" bson:"ConfirmMsg"`
-	MsgCount   uint64 `json:"MsgCount" bson:"MsgCount"`
+	ID           int            `json:"ID" bson:"ID"`
+	Confirmed    bool           `json:"Confirmed" bson:"Confirmed"`
+	ConfirmMsg   Ref            `json:"ConfirmMsg" bson:"ConfirmMsg"`
+	MsgCount     uint64         `json:"MsgCount" bson:"MsgCount"`
+	Nonce        string         `json:"Nonce" bson:"Nonce"`
+	Task         challenge.Task `json:"Task" bson:"Task"`
+	Failures     int            `json:"Failures" bson:"Failures"`
+	LinkUsed     bool           `json:"LinkUsed" bson:"LinkUsed"`
+	Restrictions *Permissions   `json:"Restrictions,omitempty" bson:"Restrictions,omitempty"`
+	InvitedBy    int            `json:"InvitedBy" bson:"InvitedBy"`
+	Approvals    []Ref          `json:"Approvals" bson:"Approvals"`
+	Probation    bool           `json:"Probation" bson:"Probation"`
+}
+
+// Permissions describes what member can send to chat
+type Permissions struct {
+	CanSendMessages       bool `json:"CanSendMessages" bson:"CanSendMessages"`
+	CanSendMediaMessages  bool `json:"CanSendMediaMessages" bson:"CanSendMediaMessages"`
+	CanSendOtherMessages  bool `json:"CanSendOtherMessages" bson:"CanSendOtherMessages"`
+	CanAddWebPagePreviews bool `json:"CanAddWebPagePreviews" bson:"CanAddWebPagePreviews"`
+}
+
+// Intersect returns permissions allowed by both p and o
+func (p Permissions) Intersect(o Permissions) Permissions {
+	return Permissions{
+		CanSendMessages:       p.CanSendMessages && o.CanSendMessages,
+		CanSendMediaMessages:  p.CanSendMediaMessages && o.CanSendMediaMessages,
+		CanSendOtherMessages:  p.CanSendOtherMessages && o.CanSendOtherMessages,
+		CanAddWebPagePreviews: p.CanAddWebPagePreviews && o.CanAddWebPagePreviews,
+	}
+}
+
+// None returns true if nothing is permitted
+func (p Permissions) None() bool {
+	return !p.CanSendMessages && !p.CanSendMediaMessages && !p.CanSendOtherMessages && !p.CanAddWebPagePreviews
+}
+
+// Events in membership history
+const (
+	MemberJoined   = "joined"
+	MemberVerified = "verified"
+	MemberLeft     = "left"
+	MemberKicked   = "kicked"
+)
+
+// MemberEvent describes change of user membership in chat
+type MemberEvent struct {
+	ChatID int64  `json:"ChatID" bson:"ChatID"`
+	UserID int    `json:"UserID" bson:"UserID"`
+	Kind   string `json:"Kind" bson:"Kind"`
+	Date   int64  `json:"Date" bson:"Date"`
+	// User which added or kicked member, zero if member acted by itself
+	ActorID int `json:"ActorID" bson:"ActorID"`
 }
 
 // Ref describe messages in chats
@@ -62,3 +311,40 @@ type Ref struct {
 	ChatID int64 `json:"ChatID" bson:"ChatID"`
 	MsgID  int   `json:"MsgID" bson:"MsgID"`
 }
+
+// Job describes deferred action which must be executed after deadline
+type Job struct {
+	Kind     string `json:"Kind" bson:"Kind"`
+	ChatID   int64  `json:"ChatID" bson:"ChatID"`
+	UserID   int    `json:"UserID" bson:"UserID"`
+	Deadline int64  `json:"Deadline" bson:"Deadline"`
+}
+
+// Federation is a group of chats sharing bans and verification trust
+type Federation struct {
+	ID      string  `json:"ID" bson:"ID"`
+	Name    string  `json:"Name" bson:"Name"`
+	OwnerID int     `json:"OwnerID" bson:"OwnerID"`
+	Chats   []int64 `json:"Chats" bson:"Chats"`
+	// Confirm users which passed the test in other chat of federation
+	TrustVerified bool            `json:"TrustVerified" bson:"TrustVerified"`
+	Bans          []FederationBan `json:"Bans" bson:"Bans"`
+}
+
+// FederationBan describes user banned in all chats of federation
+type FederationBan struct {
+	UserID int    `json:"UserID" bson:"UserID"`
+	ChatID int64  `json:"ChatID" bson:"ChatID"`
+	Reason string `json:"Reason" bson:"Reason"`
+	Date   int64  `json:"Date" bson:"Date"`
+}
+
+// Banned returns ban of user or nil if user is not banned
+func (f Federation) Banned(userID int) *FederationBan {
+	for i := range f.Bans {
+		if f.Bans[i].UserID == userID {
+			return &f.Bans[i]
+		}
+	}
+	return nil
+}
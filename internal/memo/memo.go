@@ -47,3 +47,10 @@ func (m *Memo) Get(key interface{}) (interface{}, error) {
 	return nil, errors.New("notexist")
 
 }
+
+// Delete removes key from storage
+func (m *Memo) Delete(key interface{}) {
+	m.mutex.Lock()
+	delete(m.Items, key)
+	m.mutex.Unlock()
+}
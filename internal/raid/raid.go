@@ -0,0 +1,52 @@
+package raid
+
+import (
+	"sync"
+	"time"
+)
+
+// Detector counts joins to chats in sliding window
+type Detector struct {
+	joins map[int64][]time.Time
+	mutex sync.Mutex
+}
+
+// New returns detector without registered joins
+func New() *Detector {
+	return &Detector{joins: make(map[int64][]time.Time)}
+}
+
+// Join registers join to chat and returns number of joins during window before now.
+// Chats without joins during window are forgotten.
+func (d *Detector) Join(chatID int64, now time.Time, window time.Duration) int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	since := now.Add(-window)
+	for id, joins := range d.joins {
+		recent := after(joins, since)
+		if len(recent) == 0 {
+			delete(d.joins, id)
+		} else if len(recent) < len(joins) {
+			d.joins[id] = recent
+		}
+	}
+
+	joins := append(d.joins[chatID], now)
+	d.joins[chatID] = joins
+	return len(joins)
+}
+
+// after returns copy of joins made after since, so old joins are not kept in memory
+func after(joins []time.Time, since time.Time) []time.Time {
+	i := 0
+	for i < len(joins) && !joins[i].After(since) {
+		i++
+	}
+	if i == 0 {
+		return joins
+	}
+	recent := make([]time.Time, len(joins)-i)
+	copy(recent, joins[i:])
+	return recent
+}
@@ -0,0 +1,44 @@
+package raid
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJoin(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	window := time.Minute
+	tests := []struct {
+		name   string
+		chatID int64
+		after  time.Duration
+		want   int
+	}{
+		{name: "first", chatID: 1, after: 0, want: 1},
+		{name: "second", chatID: 1, after: 10 * time.Second, want: 2},
+		{name: "other chat", chatID: 2, after: 20 * time.Second, want: 1},
+		{name: "third", chatID: 1, after: 30 * time.Second, want: 3},
+		{name: "first expired", chatID: 1, after: time.Minute, want: 3},
+		{name: "two expired", chatID: 1, after: 100 * time.Second, want: 2},
+		{name: "all expired", chatID: 1, after: 10 * time.Minute, want: 1},
+	}
+
+	d := New()
+	for _, tt := range tests {
+		if got := d.Join(tt.chatID, start.Add(tt.after), window); got != tt.want {
+			t.Errorf("%s: Join() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestJoinForgetsQuietChats(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	d := New()
+	for i := int64(0); i < 10; i++ {
+		d.Join(i, start, time.Minute)
+	}
+	d.Join(100, start.Add(2*time.Minute), time.Minute)
+	if len(d.joins) != 1 {
+		t.Errorf("Detector keeps %d chats, want 1", len(d.joins))
+	}
+}
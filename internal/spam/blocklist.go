@@ -0,0 +1,53 @@
+package spam
+
+import "sync"
+
+// Blocklist checks users in local list of spammers
+type Blocklist struct {
+	ids   map[int]struct{}
+	mutex sync.RWMutex
+}
+
+// NewBlocklist returns blocklist containing passed users
+func NewBlocklist(ids ...int) *Blocklist {
+	bl := &Blocklist{ids: make(map[int]struct{})}
+	for _, id := range ids {
+		bl.ids[id] = struct{}{}
+	}
+	return bl
+}
+
+// Name returns name of checker
+func (bl *Blocklist) Name() string {
+	return "blocklist"
+}
+
+// Check returns true if user is in list. List does not know that user is not
+// spammer, so it abstains with ErrAbstain for unlisted user.
+func (bl *Blocklist) Check(userID int) (bool, error) {
+	bl.mutex.RLock()
+	_, exist := bl.ids[userID]
+	bl.mutex.RUnlock()
+	if !exist {
+		return false, ErrAbstain
+	}
+	return true, nil
+}
+
+// Add adds users to list
+func (bl *Blocklist) Add(ids ...int) {
+	bl.mutex.Lock()
+	for _, id := range ids {
+		bl.ids[id] = struct{}{}
+	}
+	bl.mutex.Unlock()
+}
+
+// Remove removes users from list
+func (bl *Blocklist) Remove(ids ...int) {
+	bl.mutex.Lock()
+	for _, id := range ids {
+		delete(bl.ids, id)
+	}
+	bl.mutex.Unlock()
+}
@@ -0,0 +1,71 @@
+package spam
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultCASURL is address of Combot Anti-Spam service
+const DefaultCASURL = "https://api.cas.chat"
+
+type casResult struct {
+	OK bool `json:"ok"`
+}
+
+// CAS checks users in Combot Anti-Spam service
+type CAS struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+// NewCAS returns checker using service on passed address or on DefaultCASURL if it is empty
+func NewCAS(baseURL string) *CAS {
+	if baseURL == "" {
+		baseURL = DefaultCASURL
+	}
+	return &CAS{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Client: &http.Client{
+			Timeout: 3 * time.Second, // Response timeout up to 3 seconds
+			Transport: &http.Transport{
+				MaxIdleConnsPerHost: 20, // Allow 20 connections to one host
+			},
+		},
+	}
+}
+
+// Name returns name of checker
+func (c *CAS) Name() string {
+	return "CAS"
+}
+
+// Check sends request to CAS service
+func (c *CAS) Check(userID int) (bool, error) {
+	response, err := c.Client.Get(c.BaseURL + "/check?user_id=" + strconv.Itoa(userID))
+	if err != nil {
+		return false, errors.Wrap(err, "CAS: Request error")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return false, errors.New("CAS: Unexpected status " + response.Status)
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return false, errors.Wrap(err, "CAS: Body read error")
+	}
+
+	var result casResult
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		return false, errors.Wrapf(err, "CAS: JSON parsing error of body %s", string(body))
+	}
+	return result.OK, nil
+}
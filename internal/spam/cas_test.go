@@ -0,0 +1,62 @@
+package spam
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCASCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		spam    bool
+		wantErr bool
+	}{
+		{name: "spammer", status: http.StatusOK, body: `{"ok":true,"result":{"offenses":1}}`, spam: true},
+		{name: "clean", status: http.StatusOK, body: `{"ok":false,"description":"Record not found."}`},
+		{name: "bad status", status: http.StatusInternalServerError, body: `{"ok":true}`, wantErr: true},
+		{name: "bad json", status: http.StatusOK, body: `<html>`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var query string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				query = r.URL.Path + "?" + r.URL.RawQuery
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			spam, err := NewCAS(server.URL + "/").Check(42)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if spam != tt.spam {
+				t.Errorf("Check() = %v, want %v", spam, tt.spam)
+			}
+			if query != "/check?user_id=42" {
+				t.Errorf("Check() requested %s", query)
+			}
+		})
+	}
+}
+
+func TestCASCheckUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	spam, err := NewCAS(url).Check(42)
+	if err == nil || spam {
+		t.Errorf("Check() = %v, %v, want error", spam, err)
+	}
+}
+
+func TestNewCASDefaultURL(t *testing.T) {
+	if c := NewCAS(""); c.BaseURL != DefaultCASURL {
+		t.Errorf("NewCAS(\"\").BaseURL = %s, want %s", c.BaseURL, DefaultCASURL)
+	}
+}
@@ -0,0 +1,83 @@
+package spam
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// List may lose at most this share of users on single refresh
+const maxShrink = 0.5
+
+// ErrEmptyList is returned for list without user IDs, like error page or truncated download
+var ErrEmptyList = errors.New("List contains no users")
+
+// CheckList returns error if loaded list must not replace list of current size.
+// Sharp shrink of list means broken source rather than real unbans.
+func CheckList(ids []int, current int64) error {
+	if len(ids) == 0 {
+		return ErrEmptyList
+	}
+	if float64(len(ids)) < float64(current)*(1-maxShrink) {
+		return errors.Errorf("List shrank from %d to %d users", current, len(ids))
+	}
+	return nil
+}
+
+// ParseList reads user IDs from CSV where ID is the first field of line.
+// Lines which do not start with ID, like headers, are skipped.
+func ParseList(r io.Reader) ([]int, error) {
+	reader := csv.NewReader(bufio.NewReader(r))
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	ids := make([]int, 0)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return ids, errors.Wrap(err, "Failed read list")
+		}
+		if len(record) == 0 {
+			continue
+		}
+		id, err := strconv.Atoi(strings.TrimSpace(record[0]))
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// LoadList reads user IDs from URL or file path
+func LoadList(source string) ([]int, error) {
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		client := &http.Client{Timeout: 5 * time.Minute}
+		response, err := client.Get(source)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed download list %s", source)
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return nil, errors.New("Failed download list " + source + " with status " + response.Status)
+		}
+		return ParseList(response.Body)
+	}
+
+	file, err := os.Open(source)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed open list %s", source)
+	}
+	defer file.Close()
+	return ParseList(file)
+}
@@ -0,0 +1,58 @@
+package spam
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseList(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		ids     []int
+		wantErr bool
+	}{
+		{name: "empty", input: "", ids: []int{}},
+		{name: "ids only", input: "1\n2\n3\n", ids: []int{1, 2, 3}},
+		{name: "header and fields", input: "id,date,reason\n10,2020-01-01,spam\n20,2020-01-02,\"ads, links\"\n", ids: []int{10, 20}},
+		{name: "spaces and blank lines", input: " 5 ,x\n\n6\n", ids: []int{5, 6}},
+		{name: "html page", input: "<html><body>Not found</body></html>\n", ids: []int{}},
+		{name: "broken quote", input: "1\n\"2\n", ids: []int{1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, err := ParseList(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(ids, tt.ids) {
+				t.Errorf("ParseList() = %v, want %v", ids, tt.ids)
+			}
+		})
+	}
+}
+
+func TestCheckList(t *testing.T) {
+	tests := []struct {
+		name    string
+		ids     []int
+		current int64
+		wantErr bool
+	}{
+		{name: "first load", ids: []int{1}, current: 0},
+		{name: "empty", ids: []int{}, current: 0, wantErr: true},
+		{name: "grown", ids: []int{1, 2, 3}, current: 2},
+		{name: "shrank a little", ids: []int{1, 2, 3}, current: 4},
+		{name: "shrank to half", ids: []int{1, 2}, current: 4},
+		{name: "shrank sharply", ids: []int{1}, current: 4, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckList(tt.ids, tt.current)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package spam
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Checker checks user in spam reputation source
+type Checker interface {
+	// Name returns name of source used in logs
+	Name() string
+	// Check returns true if user is known spammer or ErrAbstain if checker knows nothing about user
+	Check(userID int) (bool, error)
+}
+
+// ErrAbstain is returned by checker which knows nothing about user, like list of
+// spammers for unlisted user. Such checker does not vote in Composite.
+var ErrAbstain = errors.New("User is unknown to checker")
+
+// Policy describes how verdicts of several checkers are combined
+type Policy string
+
+const (
+	// PolicyAny marks user as spammer if any checker did it
+	PolicyAny Policy = "any"
+	// PolicyMajority marks user as spammer if most of answered checkers did it
+	PolicyMajority Policy = "majority"
+	// PolicyFailClosed works as PolicyAny but also marks user as spammer if any checker failed
+	PolicyFailClosed Policy = "fail-closed"
+)
+
+// ParsePolicy returns policy by its name
+func ParsePolicy(name string) (Policy, error) {
+	switch p := Policy(name); p {
+	case PolicyAny, PolicyMajority, PolicyFailClosed:
+		return p, nil
+	default:
+		return PolicyAny, errors.New("Unknown spam policy " + name)
+	}
+}
+
+// Composite combines verdicts of several checkers
+type Composite struct {
+	Checkers []Checker
+	Policy   Policy
+}
+
+// NewComposite returns checker combining passed checkers with policy
+func NewComposite(policy Policy, checkers ...Checker) *Composite {
+	return &Composite{
+		Checkers: checkers,
+		Policy:   policy,
+	}
+}
+
+// Name returns names of all combined checkers
+func (c *Composite) Name() string {
+	list := make([]string, 0, len(c.Checkers))
+	for _, ch := range c.Checkers {
+		list = append(list, ch.Name())
+	}
+	return string(c.Policy) + "(" + strings.Join(list, ",") + ")"
+}
+
+// Check asks all checkers and combines verdicts. Error contains failures of
+// checkers, verdict is valid even if error is returned.
+func (c *Composite) Check(userID int) (bool, error) {
+	var spam, answered int
+	var failures []string
+	for _, ch := range c.Checkers {
+		isSpam, err := ch.Check(userID)
+		if err == ErrAbstain {
+			continue
+		}
+		if err != nil {
+			failures = append(failures, ch.Name()+": "+err.Error())
+			continue
+		}
+		answered++
+		if isSpam {
+			spam++
+		}
+	}
+
+	var err error
+	if len(failures) > 0 {
+		err = errors.New("Spam check failed. " + strings.Join(failures, "; "))
+	}
+
+	switch c.Policy {
+	case PolicyMajority:
+		return answered > 0 && spam*2 > answered, err
+	case PolicyFailClosed:
+		return spam > 0 || len(failures) > 0, err
+	default:
+		return spam > 0, err
+	}
+}
@@ -0,0 +1,114 @@
+package spam
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// verdict is checker returning fixed answer
+type verdict struct {
+	spam bool
+	err  error
+}
+
+func (v verdict) Name() string {
+	return "stub"
+}
+
+func (v verdict) Check(userID int) (bool, error) {
+	return v.spam, v.err
+}
+
+var (
+	yes    = verdict{spam: true}
+	no     = verdict{}
+	failed = verdict{err: errors.New("unavailable")}
+	absent = verdict{err: ErrAbstain}
+)
+
+func TestCompositeCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   Policy
+		checkers []Checker
+		spam     bool
+		wantErr  bool
+	}{
+		{name: "any without checkers", policy: PolicyAny},
+		{name: "any clean", policy: PolicyAny, checkers: []Checker{no, no}},
+		{name: "any one spam", policy: PolicyAny, checkers: []Checker{no, yes}, spam: true},
+		{name: "any failure", policy: PolicyAny, checkers: []Checker{no, failed}, wantErr: true},
+		{name: "any spam and failure", policy: PolicyAny, checkers: []Checker{failed, yes}, spam: true, wantErr: true},
+		{name: "majority most spam", policy: PolicyMajority, checkers: []Checker{yes, yes, no}, spam: true},
+		{name: "majority tie", policy: PolicyMajority, checkers: []Checker{yes, no}},
+		{name: "majority minority spam", policy: PolicyMajority, checkers: []Checker{yes, no, no}},
+		{name: "majority of answered", policy: PolicyMajority, checkers: []Checker{yes, failed, failed}, spam: true, wantErr: true},
+		{name: "majority all failed", policy: PolicyMajority, checkers: []Checker{failed, failed}, wantErr: true},
+		{name: "majority abstained", policy: PolicyMajority, checkers: []Checker{yes, absent}, spam: true},
+		{name: "majority only abstained", policy: PolicyMajority, checkers: []Checker{absent}},
+		{name: "fail-closed abstained", policy: PolicyFailClosed, checkers: []Checker{no, absent}},
+		{name: "fail-closed clean", policy: PolicyFailClosed, checkers: []Checker{no, no}},
+		{name: "fail-closed one spam", policy: PolicyFailClosed, checkers: []Checker{no, yes}, spam: true},
+		{name: "fail-closed failure", policy: PolicyFailClosed, checkers: []Checker{no, failed}, spam: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spam, err := NewComposite(tt.policy, tt.checkers...).Check(42)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if spam != tt.spam {
+				t.Errorf("Check() = %v, want %v", spam, tt.spam)
+			}
+		})
+	}
+}
+
+func TestCompositeBlocklist(t *testing.T) {
+	bl := NewBlocklist(1, 2)
+	c := NewComposite(PolicyAny, bl)
+	if spam, _ := c.Check(1); !spam {
+		t.Error("Check(1) = false, want true")
+	}
+	bl.Remove(1)
+	if spam, _ := c.Check(1); spam {
+		t.Error("Check(1) after Remove = true, want false")
+	}
+	if spam, err := c.Check(3); spam || err != nil {
+		t.Errorf("Check(3) = %v, %v, want false without error", spam, err)
+	}
+}
+
+// TestCompositeProduction checks checker built like in bot with empty SPAM_BLOCKLIST
+func TestCompositeProduction(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"ok":%v}`, r.URL.Query().Get("user_id") == "1")
+	}))
+	defer server.Close()
+
+	for _, policy := range []Policy{PolicyAny, PolicyMajority, PolicyFailClosed} {
+		c := NewComposite(policy, NewCAS(server.URL), NewBlocklist())
+		if spam, err := c.Check(1); !spam || err != nil {
+			t.Errorf("%s: Check(1) = %v, %v, want true without error", policy, spam, err)
+		}
+		if spam, err := c.Check(2); spam || err != nil {
+			t.Errorf("%s: Check(2) = %v, %v, want false without error", policy, spam, err)
+		}
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	for _, name := range []string{"any", "majority", "fail-closed"} {
+		p, err := ParsePolicy(name)
+		if err != nil || string(p) != name {
+			t.Errorf("ParsePolicy(%s) = %s, %v", name, p, err)
+		}
+	}
+	if _, err := ParsePolicy("all"); err == nil {
+		t.Error("ParsePolicy(all) returned no error")
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/pkg/errors"
 
+	"tg-group-control-bot/internal/challenge"
 	"tg-group-control-bot/internal/config"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -201,6 +202,11 @@ func (s *Storage) AddChatUser(chatID int64, cu config.ChatUser) error {
 	}
 
 	collection := s.Client.Database(s.Name).Collection("chats")
+	// Replace previous record of returning user
+	_, err = collection.UpdateOne(ctx, bson.M{"ID": chatID}, bson.M{"$pull": bson.M{"Users": bson.M{"ID": cu.ID}}})
+	if err != nil {
+		return errors.Wrap(err, "Failed remove previous user record in AddChatUser")
+	}
 	_, err = collection.UpdateOne(ctx, bson.M{"ID": chatID}, bson.M{"$push": bson.M{"Users": cu}})
 
 	return err
@@ -349,6 +355,8 @@ func (s *Storage) ConfirmChatUser(chatID int64, userID int) (config.Ref, error)
 			"$set": bson.M{
 				"Users.$.Confirmed":  true,
 				"Users.$.ConfirmMsg": bson.M{"ChatID": 0, "MsgID": 0},
+				"Users.$.Nonce":      "",
+				"Users.$.Task":       challenge.Task{},
 			},
 		})
 		if err != nil {
@@ -368,10 +376,13 @@ func (s *Storage) RemoveChatAdmin(chatID int64, userID int) error {
 	}
 	collection := s.Client.Database(s.Name).Collection("chats")
 	_, err = collection.UpdateOne(ctx, bson.M{"ID": chatID}, bson.M{
-		"$pull": bson.M{"Admins": userID},
+		"$pull": bson.M{
+			"Admins":      userID,
+			"AdminRights": bson.M{"ID": userID},
+		},
 	})
 	if err != nil {
-		return errors.Wrap(err, "Failed remove user in RemoveUnconfirmedChatUser")
+		return errors.Wrap(err, "Failed remove user in RemoveChatAdmin")
 	}
 	return nil
 }
@@ -403,3 +414,885 @@ func (s *Storage) DeleteUnconfirmedChat(chatID int64, userID int) error {
 
 	return err
 }
+
+// AddJob saves job or replaces deadline of the same job
+func (s *Storage) AddJob(job config.Job) error {
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return errors.Wrap(err, "Failed ping in AddJob")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("jobs")
+	_, err = collection.ReplaceOne(ctx, bson.M{
+		"Kind":   job.Kind,
+		"ChatID": job.ChatID,
+		"UserID": job.UserID,
+	}, job, options.Replace().SetUpsert(true))
+	if err != nil {
+		return errors.Wrap(err, "Failed upsert in AddJob")
+	}
+	return nil
+}
+
+// DueJobs returns jobs with deadline before passed time
+func (s *Storage) DueJobs(now int64) ([]config.Job, error) {
+	jobs := make([]config.Job, 0)
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return jobs, errors.Wrap(err, "Failed ping in DueJobs")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("jobs")
+	cursor, err := collection.Find(ctx, bson.M{"Deadline": bson.M{"$lte": now}})
+	if err != nil {
+		return jobs, errors.Wrap(err, "Failed find in DueJobs")
+	}
+	err = cursor.All(ctx, &jobs)
+	if err != nil {
+		return jobs, errors.Wrap(err, "Failed decode in DueJobs")
+	}
+	return jobs, nil
+}
+
+// RemoveJob removes job of passed kind for user in chat
+func (s *Storage) RemoveJob(kind string, chatID int64, userID int) error {
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return errors.Wrap(err, "Failed ping in RemoveJob")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("jobs")
+	_, err = collection.DeleteOne(ctx, bson.M{
+		"Kind":   kind,
+		"ChatID": chatID,
+		"UserID": userID,
+	})
+	if err != nil {
+		return errors.Wrap(err, "Failed delete in RemoveJob")
+	}
+	return nil
+}
+
+// GetChatSettings returns chat settings or defaults if chat was not configured
+func (s *Storage) GetChatSettings(chatID int64) (config.ChatSettings, error) {
+	settings := config.DefaultChatSettings()
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return settings, errors.Wrap(err, "Failed ping in GetChatSettings")
+	}
+
+	var c config.Chat
+	collection := s.Client.Database(s.Name).Collection("chats")
+	err = collection.FindOne(ctx, bson.M{"ID": chatID}, options.FindOne().SetProjection(bson.M{
+		"_id":      0,
+		"Settings": 1,
+	})).Decode(&c)
+	if err != nil {
+		return settings, errors.Wrap(err, "Failed find in GetChatSettings")
+	}
+
+	if c.Settings != nil {
+		settings = *c.Settings
+		settings.Fill()
+	}
+	return settings, nil
+}
+
+// UpdateChatSettings saves chat settings
+func (s *Storage) UpdateChatSettings(chatID int64, settings config.ChatSettings) error {
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return errors.Wrap(err, "Failed ping in UpdateChatSettings")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("chats")
+	_, err = collection.UpdateOne(ctx, bson.M{"ID": chatID}, bson.M{"$set": bson.M{"Settings": settings}})
+	if err != nil {
+		return errors.Wrap(err, "Failed update in UpdateChatSettings")
+	}
+	return nil
+}
+
+// GetAdminChats returns chats where user is admin
+func (s *Storage) GetAdminChats(userID int) ([]config.Chat, error) {
+	chats := make([]config.Chat, 0)
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return chats, errors.Wrap(err, "Failed ping in GetAdminChats")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("chats")
+	cursor, err := collection.Find(ctx, bson.M{"Admins": userID}, options.Find().SetProjection(bson.M{
+		"_id":   0,
+		"Users": 0,
+	}))
+	if err != nil {
+		return chats, errors.Wrap(err, "Failed find in GetAdminChats")
+	}
+	err = cursor.All(ctx, &chats)
+	if err != nil {
+		return chats, errors.Wrap(err, "Failed decode in GetAdminChats")
+	}
+	return chats, nil
+}
+
+// GetChatUser returns user of chat
+func (s *Storage) GetChatUser(chatID int64, userID int) (config.ChatUser, error) {
+	var cu config.ChatUser
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return cu, errors.Wrap(err, "Failed ping in GetChatUser")
+	}
+
+	var c config.Chat
+	collection := s.Client.Database(s.Name).Collection("chats")
+	err = collection.FindOne(ctx, bson.M{"ID": chatID}, options.FindOne().SetProjection(bson.M{
+		"_id": 0,
+		"Users": bson.M{
+			"$elemMatch": bson.M{"ID": userID},
+		},
+	})).Decode(&c)
+	if err != nil {
+		return cu, errors.Wrap(err, "Failed find in GetChatUser")
+	}
+
+	if len(c.Users) == 0 {
+		return cu, mongo.ErrNoDocuments
+	}
+	return c.Users[0], nil
+}
+
+// UpdateChatUserTask saves task issued to user of chat
+func (s *Storage) UpdateChatUserTask(chatID int64, userID int, task challenge.Task) error {
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return errors.Wrap(err, "Failed ping in UpdateChatUserTask")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("chats")
+	_, err = collection.UpdateOne(ctx, bson.M{"ID": chatID, "Users.ID": userID}, bson.M{"$set": bson.M{"Users.$.Task": task}})
+	if err != nil {
+		return errors.Wrap(err, "Failed update in UpdateChatUserTask")
+	}
+	return nil
+}
+
+// AddChatQuestion adds question to chat's question bank
+func (s *Storage) AddChatQuestion(chatID int64, q challenge.Item) error {
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return errors.Wrap(err, "Failed ping in AddChatQuestion")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("chats")
+	_, err = collection.UpdateOne(ctx, bson.M{"ID": chatID}, bson.M{"$push": bson.M{"Questions": q}})
+	if err != nil {
+		return errors.Wrap(err, "Failed update in AddChatQuestion")
+	}
+	return nil
+}
+
+// GetChatQuestions returns chat's question bank
+func (s *Storage) GetChatQuestions(chatID int64) ([]challenge.Item, error) {
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed ping in GetChatQuestions")
+	}
+
+	var c config.Chat
+	collection := s.Client.Database(s.Name).Collection("chats")
+	err = collection.FindOne(ctx, bson.M{"ID": chatID}, options.FindOne().SetProjection(bson.M{
+		"_id":       0,
+		"Questions": 1,
+	})).Decode(&c)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed find in GetChatQuestions")
+	}
+	return c.Questions, nil
+}
+
+// RemoveChatQuestion removes question with passed index from chat's question bank
+func (s *Storage) RemoveChatQuestion(chatID int64, index int) (challenge.Item, error) {
+	var q challenge.Item
+	questions, err := s.GetChatQuestions(chatID)
+	if err != nil {
+		return q, err
+	}
+	if index < 0 || index >= len(questions) {
+		return q, errors.New("Question " + strconv.Itoa(index+1) + " does not exist")
+	}
+	q = questions[index]
+	questions = append(questions[:index], questions[index+1:]...)
+
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return q, errors.Wrap(err, "Failed ping in RemoveChatQuestion")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("chats")
+	_, err = collection.UpdateOne(ctx, bson.M{"ID": chatID}, bson.M{"$set": bson.M{"Questions": questions}})
+	if err != nil {
+		return q, errors.Wrap(err, "Failed update in RemoveChatQuestion")
+	}
+	return q, nil
+}
+
+// IncChatUserFailures increments number of user's wrong answers and returns it
+func (s *Storage) IncChatUserFailures(chatID int64, userID int) (int, error) {
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed ping in IncChatUserFailures")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("chats")
+	_, err = collection.UpdateOne(ctx, bson.M{"ID": chatID, "Users.ID": userID}, bson.M{"$inc": bson.M{"Users.$.Failures": 1}})
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed update in IncChatUserFailures")
+	}
+
+	cu, err := s.GetChatUser(chatID, userID)
+	return cu.Failures, err
+}
+
+// GetUser returns user without updating activity
+func (s *Storage) GetUser(userID int) (config.User, error) {
+	var u config.User
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return u, errors.Wrap(err, "Failed ping in GetUser")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("users")
+	err = collection.FindOne(ctx, bson.M{"ID": userID}).Decode(&u)
+	if err != nil {
+		return u, errors.Wrap(err, "Failed find in GetUser")
+	}
+	return u, nil
+}
+
+// SetActiveChat saves chat which test user passes in private chat with bot
+func (s *Storage) SetActiveChat(userID int, chatID int64) error {
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return errors.Wrap(err, "Failed ping in SetActiveChat")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("users")
+	_, err = collection.UpdateOne(ctx, bson.M{"ID": userID}, bson.M{"$set": bson.M{"ActiveChat": chatID}})
+	if err != nil {
+		return errors.Wrap(err, "Failed update in SetActiveChat")
+	}
+	return nil
+}
+
+// SetChatUserLinkUsed marks link to the test of user as used
+func (s *Storage) SetChatUserLinkUsed(chatID int64, userID int) error {
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return errors.Wrap(err, "Failed ping in SetChatUserLinkUsed")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("chats")
+	_, err = collection.UpdateOne(ctx, bson.M{"ID": chatID, "Users.ID": userID}, bson.M{"$set": bson.M{"Users.$.LinkUsed": true}})
+	if err != nil {
+		return errors.Wrap(err, "Failed update in SetChatUserLinkUsed")
+	}
+	return nil
+}
+
+// SetChatAdmins replaces admins of chat
+func (s *Storage) SetChatAdmins(chatID int64, admins []config.AdminRights) error {
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return errors.Wrap(err, "Failed ping in SetChatAdmins")
+	}
+
+	ids := make([]int, 0, len(admins))
+	for _, adm := range admins {
+		ids = append(ids, adm.ID)
+	}
+
+	collection := s.Client.Database(s.Name).Collection("chats")
+	_, err = collection.UpdateOne(ctx, bson.M{"ID": chatID}, bson.M{"$set": bson.M{
+		"Admins":      ids,
+		"AdminRights": admins,
+	}})
+	if err != nil {
+		return errors.Wrap(err, "Failed update in SetChatAdmins")
+	}
+	return nil
+}
+
+// GetChatIDs returns IDs of all chats where bot placed
+func (s *Storage) GetChatIDs() ([]int64, error) {
+	ids := make([]int64, 0)
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return ids, errors.Wrap(err, "Failed ping in GetChatIDs")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("chats")
+	cursor, err := collection.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"_id": 0, "ID": 1}))
+	if err != nil {
+		return ids, errors.Wrap(err, "Failed find in GetChatIDs")
+	}
+	var chats []config.Chat
+	err = cursor.All(ctx, &chats)
+	if err != nil {
+		return ids, errors.Wrap(err, "Failed decode in GetChatIDs")
+	}
+	for _, c := range chats {
+		ids = append(ids, c.ID)
+	}
+	return ids, nil
+}
+
+// UpdateSpammers replaces local list of spammers with passed users
+func (s *Storage) UpdateSpammers(ids []int) error {
+	_, cancelCheckCtx, err := s.checkDB()
+	cancelCheckCtx()
+	if err != nil {
+		return errors.Wrap(err, "Failed ping in UpdateSpammers")
+	}
+
+	// Lists may contain millions of users
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancelCtx()
+
+	collection := s.Client.Database(s.Name).Collection("spammers")
+	_, err = collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"ID": 1},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return errors.Wrap(err, "Failed create index in UpdateSpammers")
+	}
+
+	// Mark actual users with new version and then remove others
+	version := time.Now().UnixNano()
+	const batchSize = 5000
+	for start := 0; start < len(ids); start += batchSize {
+		end := start + batchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		models := make([]mongo.WriteModel, 0, end-start)
+		for _, id := range ids[start:end] {
+			models = append(models, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"ID": id}).
+				SetUpdate(bson.M{"$set": bson.M{"Version": version}}).
+				SetUpsert(true))
+		}
+		_, err = collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			return errors.Wrap(err, "Failed bulk write in UpdateSpammers")
+		}
+	}
+
+	_, err = collection.DeleteMany(ctx, bson.M{"Version": bson.M{"$ne": version}})
+	if err != nil {
+		return errors.Wrap(err, "Failed delete in UpdateSpammers")
+	}
+	return nil
+}
+
+// CountSpammers returns size of local list of spammers
+func (s *Storage) CountSpammers() (int64, error) {
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed ping in CountSpammers")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("spammers")
+	count, err := collection.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed count in CountSpammers")
+	}
+	return count, nil
+}
+
+// IsSpammer checks user in local list of spammers
+func (s *Storage) IsSpammer(userID int) (bool, error) {
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return false, errors.Wrap(err, "Failed ping in IsSpammer")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("spammers")
+	count, err := collection.CountDocuments(ctx, bson.M{"ID": userID}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, errors.Wrap(err, "Failed count in IsSpammer")
+	}
+	return count > 0, nil
+}
+
+// RemoveChatUser removes user from chat
+func (s *Storage) RemoveChatUser(chatID int64, userID int) error {
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return errors.Wrap(err, "Failed ping in RemoveChatUser")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("chats")
+	_, err = collection.UpdateOne(ctx, bson.M{"ID": chatID}, bson.M{"$pull": bson.M{"Users": bson.M{"ID": userID}}})
+	if err != nil {
+		return errors.Wrap(err, "Failed update in RemoveChatUser")
+	}
+	return nil
+}
+
+// SetUserBan bans or unbans user in all chats
+func (s *Storage) SetUserBan(userID int, banned bool, reason string) error {
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return errors.Wrap(err, "Failed ping in SetUserBan")
+	}
+
+	var banDate int64
+	if banned {
+		banDate = time.Now().Unix()
+	}
+
+	collection := s.Client.Database(s.Name).Collection("users")
+	_, err = collection.UpdateOne(ctx, bson.M{"ID": userID}, bson.M{"$set": bson.M{
+		"Banned":    banned,
+		"BanDate":   banDate,
+		"BanReason": reason,
+	}}, options.Update().SetUpsert(true))
+	if err != nil {
+		return errors.Wrap(err, "Failed update in SetUserBan")
+	}
+	return nil
+}
+
+// GetBannedUsers returns users banned in all chats
+func (s *Storage) GetBannedUsers() ([]config.User, error) {
+	users := make([]config.User, 0)
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return users, errors.Wrap(err, "Failed ping in GetBannedUsers")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("users")
+	cursor, err := collection.Find(ctx, bson.M{"Banned": true}, options.Find().SetSort(bson.M{"BanDate": -1}))
+	if err != nil {
+		return users, errors.Wrap(err, "Failed find in GetBannedUsers")
+	}
+	err = cursor.All(ctx, &users)
+	if err != nil {
+		return users, errors.Wrap(err, "Failed decode in GetBannedUsers")
+	}
+	return users, nil
+}
+
+// CreateFederation saves new federation
+func (s *Storage) CreateFederation(f config.Federation) error {
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return errors.Wrap(err, "Failed ping in CreateFederation")
+	}
+
+	if f.Chats == nil {
+		f.Chats = make([]int64, 0)
+	}
+	if f.Bans == nil {
+		f.Bans = make([]config.FederationBan, 0)
+	}
+	collection := s.Client.Database(s.Name).Collection("federations")
+	_, err = collection.InsertOne(ctx, f)
+	if err != nil {
+		return errors.Wrap(err, "Failed insert in CreateFederation")
+	}
+	return nil
+}
+
+// GetFederation returns federation by ID
+func (s *Storage) GetFederation(id string) (config.Federation, error) {
+	return s.findFederation(bson.M{"ID": id})
+}
+
+// GetChatFederation returns federation which contains chat
+func (s *Storage) GetChatFederation(chatID int64) (config.Federation, error) {
+	return s.findFederation(bson.M{"Chats": chatID})
+}
+
+func (s *Storage) findFederation(filter bson.M) (config.Federation, error) {
+	var f config.Federation
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return f, errors.Wrap(err, "Failed ping in findFederation")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("federations")
+	err = collection.FindOne(ctx, filter).Decode(&f)
+	return f, err
+}
+
+// GetOwnerFederations returns federations of user
+func (s *Storage) GetOwnerFederations(ownerID int) ([]config.Federation, error) {
+	feds := make([]config.Federation, 0)
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return feds, errors.Wrap(err, "Failed ping in GetOwnerFederations")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("federations")
+	cursor, err := collection.Find(ctx, bson.M{"OwnerID": ownerID})
+	if err != nil {
+		return feds, errors.Wrap(err, "Failed find in GetOwnerFederations")
+	}
+	err = cursor.All(ctx, &feds)
+	if err != nil {
+		return feds, errors.Wrap(err, "Failed decode in GetOwnerFederations")
+	}
+	return feds, nil
+}
+
+// AddFederationChat moves chat to federation. Chat can be in one federation only.
+func (s *Storage) AddFederationChat(id string, chatID int64) error {
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return errors.Wrap(err, "Failed ping in AddFederationChat")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("federations")
+	_, err = collection.UpdateMany(ctx, bson.M{"Chats": chatID}, bson.M{"$pull": bson.M{"Chats": chatID}})
+	if err != nil {
+		return errors.Wrap(err, "Failed remove chat in AddFederationChat")
+	}
+	_, err = collection.UpdateOne(ctx, bson.M{"ID": id}, bson.M{"$addToSet": bson.M{"Chats": chatID}})
+	if err != nil {
+		return errors.Wrap(err, "Failed update in AddFederationChat")
+	}
+	return nil
+}
+
+// RemoveFederationChat removes chat from federation
+func (s *Storage) RemoveFederationChat(id string, chatID int64) error {
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return errors.Wrap(err, "Failed ping in RemoveFederationChat")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("federations")
+	_, err = collection.UpdateOne(ctx, bson.M{"ID": id}, bson.M{"$pull": bson.M{"Chats": chatID}})
+	if err != nil {
+		return errors.Wrap(err, "Failed update in RemoveFederationChat")
+	}
+	return nil
+}
+
+// SetFederationTrust enables or disables confirmation of users verified in other chats of federation
+func (s *Storage) SetFederationTrust(id string, trust bool) error {
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return errors.Wrap(err, "Failed ping in SetFederationTrust")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("federations")
+	_, err = collection.UpdateOne(ctx, bson.M{"ID": id}, bson.M{"$set": bson.M{"TrustVerified": trust}})
+	if err != nil {
+		return errors.Wrap(err, "Failed update in SetFederationTrust")
+	}
+	return nil
+}
+
+// AddFederationBan bans user in federation
+func (s *Storage) AddFederationBan(id string, ban config.FederationBan) error {
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return errors.Wrap(err, "Failed ping in AddFederationBan")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("federations")
+	_, err = collection.UpdateOne(ctx, bson.M{"ID": id}, bson.M{"$pull": bson.M{"Bans": bson.M{"UserID": ban.UserID}}})
+	if err != nil {
+		return errors.Wrap(err, "Failed remove old ban in AddFederationBan")
+	}
+	_, err = collection.UpdateOne(ctx, bson.M{"ID": id}, bson.M{"$push": bson.M{"Bans": ban}})
+	if err != nil {
+		return errors.Wrap(err, "Failed update in AddFederationBan")
+	}
+	return nil
+}
+
+// RemoveFederationBan unbans user in federation
+func (s *Storage) RemoveFederationBan(id string, userID int) error {
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return errors.Wrap(err, "Failed ping in RemoveFederationBan")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("federations")
+	_, err = collection.UpdateOne(ctx, bson.M{"ID": id}, bson.M{"$pull": bson.M{"Bans": bson.M{"UserID": userID}}})
+	if err != nil {
+		return errors.Wrap(err, "Failed update in RemoveFederationBan")
+	}
+	return nil
+}
+
+// UserConfirmedInChats checks that user passed the test in any of passed chats
+func (s *Storage) UserConfirmedInChats(chatIDs []int64, userID int) (bool, error) {
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return false, errors.Wrap(err, "Failed ping in UserConfirmedInChats")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("chats")
+	count, err := collection.CountDocuments(ctx, bson.M{
+		"ID": bson.M{"$in": chatIDs},
+		"Users": bson.M{
+			"$elemMatch": bson.M{"ID": userID, "Confirmed": true},
+		},
+	}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, errors.Wrap(err, "Failed count in UserConfirmedInChats")
+	}
+	return count > 0, nil
+}
+
+// AddMemberEvent saves event to membership history
+func (s *Storage) AddMemberEvent(e config.MemberEvent) error {
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return errors.Wrap(err, "Failed ping in AddMemberEvent")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("history")
+	_, err = collection.InsertOne(ctx, e)
+	if err != nil {
+		return errors.Wrap(err, "Failed insert in AddMemberEvent")
+	}
+	return nil
+}
+
+// GetMemberHistory returns membership history of user in chat from newest to oldest events
+func (s *Storage) GetMemberHistory(chatID int64, userID int, limit int64) ([]config.MemberEvent, error) {
+	events := make([]config.MemberEvent, 0)
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return events, errors.Wrap(err, "Failed ping in GetMemberHistory")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("history")
+	cursor, err := collection.Find(ctx, bson.M{"ChatID": chatID, "UserID": userID},
+		options.Find().SetSort(bson.D{{Key: "Date", Value: -1}, {Key: "_id", Value: -1}}).SetLimit(limit))
+	if err != nil {
+		return events, errors.Wrap(err, "Failed find in GetMemberHistory")
+	}
+	err = cursor.All(ctx, &events)
+	if err != nil {
+		return events, errors.Wrap(err, "Failed decode in GetMemberHistory")
+	}
+	return events, nil
+}
+
+// LastMemberEvent returns the newest event of passed kinds for user in chat
+func (s *Storage) LastMemberEvent(chatID int64, userID int, kinds ...string) (config.MemberEvent, error) {
+	var e config.MemberEvent
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return e, errors.Wrap(err, "Failed ping in LastMemberEvent")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("history")
+	err = collection.FindOne(ctx, bson.M{
+		"ChatID": chatID,
+		"UserID": userID,
+		"Kind":   bson.M{"$in": kinds},
+	}, options.FindOne().SetSort(bson.D{{Key: "Date", Value: -1}, {Key: "_id", Value: -1}})).Decode(&e)
+	return e, err
+}
+
+// SetChatTrusted adds or removes trusted member of chat
+func (s *Storage) SetChatTrusted(chatID int64, userID int, trusted bool) error {
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return errors.Wrap(err, "Failed ping in SetChatTrusted")
+	}
+
+	update := bson.M{"$pull": bson.M{"Trusted": userID}}
+	if trusted {
+		update = bson.M{"$addToSet": bson.M{"Trusted": userID}}
+	}
+	collection := s.Client.Database(s.Name).Collection("chats")
+	_, err = collection.UpdateOne(ctx, bson.M{"ID": chatID}, update)
+	if err != nil {
+		return errors.Wrap(err, "Failed update in SetChatTrusted")
+	}
+	return nil
+}
+
+// SetChatAllowedBot adds or removes bot from allowlist of chat
+func (s *Storage) SetChatAllowedBot(chatID int64, botID int, allowed bool) error {
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return errors.Wrap(err, "Failed ping in SetChatAllowedBot")
+	}
+
+	update := bson.M{"$pull": bson.M{"AllowedBots": botID}}
+	if allowed {
+		update = bson.M{"$addToSet": bson.M{"AllowedBots": botID}}
+	}
+	collection := s.Client.Database(s.Name).Collection("chats")
+	_, err = collection.UpdateOne(ctx, bson.M{"ID": chatID}, update)
+	if err != nil {
+		return errors.Wrap(err, "Failed update in SetChatAllowedBot")
+	}
+	return nil
+}
+
+// SetChatUserApprovals saves approval requests sent to admins about user
+func (s *Storage) SetChatUserApprovals(chatID int64, userID int, refs []config.Ref) error {
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return errors.Wrap(err, "Failed ping in SetChatUserApprovals")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("chats")
+	_, err = collection.UpdateOne(ctx, bson.M{"ID": chatID, "Users.ID": userID}, bson.M{
+		"$set": bson.M{"Users.$.Approvals": refs},
+	})
+	if err != nil {
+		return errors.Wrap(err, "Failed update in SetChatUserApprovals")
+	}
+	return nil
+}
+
+// ClaimApproval atomically takes approval requests of user, so that only one admin can resolve them.
+// Returns mongo.ErrNoDocuments if requests were already resolved.
+func (s *Storage) ClaimApproval(chatID int64, userID int) ([]config.Ref, error) {
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed ping in ClaimApproval")
+	}
+
+	var c config.Chat
+	collection := s.Client.Database(s.Name).Collection("chats")
+	err = collection.FindOneAndUpdate(ctx, bson.M{
+		"ID": chatID,
+		"Users": bson.M{
+			"$elemMatch": bson.M{"ID": userID, "Confirmed": false, "Approvals.0": bson.M{"$exists": true}},
+		},
+	}, bson.M{
+		"$set": bson.M{"Users.$.Approvals": []config.Ref{}},
+	}, options.FindOneAndUpdate().SetProjection(bson.M{
+		"_id": 0,
+		"Users": bson.M{
+			"$elemMatch": bson.M{"ID": userID},
+		},
+	})).Decode(&c)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.Users) == 0 {
+		return nil, mongo.ErrNoDocuments
+	}
+	return c.Users[0].Approvals, nil
+}
+
+// SetChatLockdown turns lockdown of chat on or off. Returns false if lockdown was already in passed state.
+func (s *Storage) SetChatLockdown(chatID int64, lockdown bool) (bool, error) {
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return false, errors.Wrap(err, "Failed ping in SetChatLockdown")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("chats")
+	res, err := collection.UpdateOne(ctx, bson.M{
+		"ID":       chatID,
+		"Lockdown": bson.M{"$ne": lockdown},
+	}, bson.M{"$set": bson.M{"Lockdown": lockdown}})
+	if err != nil {
+		return false, errors.Wrap(err, "Failed update in SetChatLockdown")
+	}
+	return res.ModifiedCount > 0, nil
+}
+
+// SetChatUserProbation starts or ends probation of verified user.
+// Returns false if probation was already in passed state.
+func (s *Storage) SetChatUserProbation(chatID int64, userID int, probation bool) (bool, error) {
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return false, errors.Wrap(err, "Failed ping in SetChatUserProbation")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("chats")
+	res, err := collection.UpdateOne(ctx, bson.M{
+		"ID": chatID,
+		"Users": bson.M{
+			"$elemMatch": bson.M{"ID": userID, "Probation": bson.M{"$ne": probation}},
+		},
+	}, bson.M{"$set": bson.M{"Users.$.Probation": probation}})
+	if err != nil {
+		return false, errors.Wrap(err, "Failed update in SetChatUserProbation")
+	}
+	return res.ModifiedCount > 0, nil
+}
+
+// IncChatUserMessages increments counter of user messages in chat and returns updated user
+func (s *Storage) IncChatUserMessages(chatID int64, userID int) (config.ChatUser, error) {
+	var c config.Chat
+	ctx, cancelCtx, err := s.checkDB()
+	defer cancelCtx()
+	if err != nil {
+		return config.ChatUser{}, errors.Wrap(err, "Failed ping in IncChatUserMessages")
+	}
+
+	collection := s.Client.Database(s.Name).Collection("chats")
+	err = collection.FindOneAndUpdate(ctx, bson.M{"ID": chatID, "Users.ID": userID},
+		bson.M{"$inc": bson.M{"Users.$.MsgCount": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{
+			"_id": 0,
+			"Users": bson.M{
+				"$elemMatch": bson.M{"ID": userID},
+			},
+		})).Decode(&c)
+	if err != nil {
+		return config.ChatUser{}, err
+	}
+	if len(c.Users) == 0 {
+		return config.ChatUser{}, mongo.ErrNoDocuments
+	}
+	return c.Users[0], nil
+}
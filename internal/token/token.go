@@ -0,0 +1,66 @@
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// Length of signature in bytes. Telegram limits deep link payload to 64 characters.
+const signatureSize = 12
+
+// Size of payload fields: chat ID, user ID and expiration time
+const payloadSize = 8 + 8 + 8
+
+var (
+	// ErrInvalid is returned for malformed or forged tokens
+	ErrInvalid = errors.New("invalid token")
+	// ErrExpired is returned for tokens with passed expiration time
+	ErrExpired = errors.New("expired token")
+)
+
+// Payload contains data bound to token
+type Payload struct {
+	ChatID  int64
+	UserID  int
+	Expires int64
+}
+
+// Sign returns URL safe token with signed payload
+func Sign(secret []byte, p Payload) string {
+	data := make([]byte, payloadSize, payloadSize+signatureSize)
+	binary.BigEndian.PutUint64(data[0:], uint64(p.ChatID))
+	binary.BigEndian.PutUint64(data[8:], uint64(p.UserID))
+	binary.BigEndian.PutUint64(data[16:], uint64(p.Expires))
+	data = append(data, signature(secret, data)...)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Verify checks signature and expiration time of token and returns its payload
+func Verify(secret []byte, token string, now int64) (Payload, error) {
+	var p Payload
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(data) != payloadSize+signatureSize {
+		return p, ErrInvalid
+	}
+	if !hmac.Equal(data[payloadSize:], signature(secret, data[:payloadSize])) {
+		return p, ErrInvalid
+	}
+
+	p.ChatID = int64(binary.BigEndian.Uint64(data[0:]))
+	p.UserID = int(binary.BigEndian.Uint64(data[8:]))
+	p.Expires = int64(binary.BigEndian.Uint64(data[16:]))
+	if p.Expires < now {
+		return p, ErrExpired
+	}
+	return p, nil
+}
+
+func signature(secret, data []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(data)
+	return mac.Sum(nil)[:signatureSize]
+}
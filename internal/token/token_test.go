@@ -0,0 +1,83 @@
+package token
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+var secret = []byte("secret")
+
+func TestSignVerify(t *testing.T) {
+	payloads := []Payload{
+		{ChatID: -1001234567890, UserID: 123456789, Expires: 1600000000},
+		{ChatID: 1, UserID: 1, Expires: 0},
+		{ChatID: -1 << 62, UserID: 1<<31 - 1, Expires: 1<<62 - 1},
+	}
+	for _, p := range payloads {
+		token := Sign(secret, p)
+		if len(token) >= 64 {
+			t.Errorf("Sign(%+v) length = %d, want less than 64", p, len(token))
+		}
+		got, err := Verify(secret, token, p.Expires)
+		if err != nil {
+			t.Errorf("Verify(%s) error = %v", token, err)
+		}
+		if got != p {
+			t.Errorf("Verify(%s) = %+v, want %+v", token, got, p)
+		}
+	}
+}
+
+func TestVerifyInvalid(t *testing.T) {
+	p := Payload{ChatID: -1001234567890, UserID: 123456789, Expires: 1600000000}
+	token := Sign(secret, p)
+	data, _ := base64.RawURLEncoding.DecodeString(token)
+
+	tampered := append([]byte{}, data...)
+	tampered[15]++ // user ID
+
+	forgedSignature := append([]byte{}, data...)
+	forgedSignature[len(forgedSignature)-1]++
+
+	tests := []struct {
+		name   string
+		secret []byte
+		token  string
+		err    error
+	}{
+		{name: "valid", secret: secret, token: token},
+		{name: "other secret", secret: []byte("other"), token: token, err: ErrInvalid},
+		{name: "tampered payload", secret: secret, token: base64.RawURLEncoding.EncodeToString(tampered), err: ErrInvalid},
+		{name: "forged signature", secret: secret, token: base64.RawURLEncoding.EncodeToString(forgedSignature), err: ErrInvalid},
+		{name: "truncated", secret: secret, token: base64.RawURLEncoding.EncodeToString(data[:len(data)-1]), err: ErrInvalid},
+		{name: "extended", secret: secret, token: base64.RawURLEncoding.EncodeToString(append(data, 0)), err: ErrInvalid},
+		{name: "not base64", secret: secret, token: token[:len(token)-1] + "*", err: ErrInvalid},
+		{name: "empty", secret: secret, token: "", err: ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Verify(tt.secret, tt.token, p.Expires)
+			if err != tt.err {
+				t.Errorf("Verify() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestVerifyExpired(t *testing.T) {
+	p := Payload{ChatID: -100, UserID: 1, Expires: 1000}
+	token := Sign(secret, p)
+	tests := []struct {
+		now int64
+		err error
+	}{
+		{now: 999},
+		{now: 1000},
+		{now: 1001, err: ErrExpired},
+	}
+	for _, tt := range tests {
+		if _, err := Verify(secret, token, tt.now); err != tt.err {
+			t.Errorf("Verify() at %d error = %v, want %v", tt.now, err, tt.err)
+		}
+	}
+}